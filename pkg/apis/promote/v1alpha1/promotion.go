@@ -0,0 +1,145 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Promotion represents a request to promote a version of an app to environments which is reconciled by the
+// 'jx promote controller' command so that pipelines do not need to wait for the promotion to complete
+//
+// +k8s:openapi-gen=true
+type Promotion struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata"`
+
+	// Spec holds the app, version and environments to promote to
+	Spec PromotionSpec `json:"spec"`
+
+	// Status holds the progress of the promotion
+	// +optional
+	Status PromotionStatus `json:"status,omitempty"`
+}
+
+// PromotionStrategy which environments to promote to if no environments are specified
+type PromotionStrategy string
+
+const (
+	// PromotionStrategyAuto promotes to all the automatic environments in order
+	PromotionStrategyAuto PromotionStrategy = "auto"
+
+	// PromotionStrategyAll promotes to all the automatic and manual environments in order using a draft Pull Request
+	// for the manual environments
+	PromotionStrategyAll PromotionStrategy = "all"
+)
+
+// PromotionSpec defines the app, version and environments to promote to
+type PromotionSpec struct {
+	// App the name of the app to promote
+	App string `json:"app"`
+
+	// Version the version of the app to promote
+	Version string `json:"version"`
+
+	// Environments the names of the environments to promote to. Defaults to the environments of the Strategy
+	Environments []string `json:"environments,omitempty"`
+
+	// Strategy which environments to promote to if no Environments are specified. Either 'auto' to promote to all
+	// automatic environments or 'all' to also promote to manual environments. Defaults to 'auto'
+	Strategy PromotionStrategy `json:"strategy,omitempty"`
+
+	// ReleaseName the name of the helm release. Defaults to the App
+	ReleaseName string `json:"releaseName,omitempty"`
+
+	// HelmRepositoryURL the URL of the helm repository containing the chart of the app
+	HelmRepositoryURL string `json:"helmRepositoryURL,omitempty"`
+
+	// AppGitURL the git URL of the app. Only required if using file or kpt rules
+	AppGitURL string `json:"appGitURL,omitempty"`
+
+	// Pipeline the pipeline in the form 'owner/repository/branch' of the PipelineActivity updated with the
+	// progress of the promotion. Defaults to one derived from the name of the Promotion
+	Pipeline string `json:"pipeline,omitempty"`
+
+	// Build the build number of the PipelineActivity. Defaults to the generation of the Promotion
+	Build string `json:"build,omitempty"`
+
+	// Timeout the duration to wait for the promotion to complete such as '1h'. Defaults to the --timeout of the
+	// controller
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// PromotionPhase the phase of a promotion
+type PromotionPhase string
+
+const (
+	// PromotionPhasePending the promotion has not started yet
+	PromotionPhasePending PromotionPhase = "Pending"
+
+	// PromotionPhaseRunning the promotion is in progress
+	PromotionPhaseRunning PromotionPhase = "Running"
+
+	// PromotionPhaseSucceeded the promotion completed successfully
+	PromotionPhaseSucceeded PromotionPhase = "Succeeded"
+
+	// PromotionPhaseFailed the promotion failed
+	PromotionPhaseFailed PromotionPhase = "Failed"
+)
+
+const (
+	// PromotionConditionSucceeded the condition which is true once the promotion has completed, false if it failed
+	// and unknown while it is in progress
+	PromotionConditionSucceeded = "Succeeded"
+)
+
+// PromotionStatus defines the progress of a promotion
+type PromotionStatus struct {
+	// Phase the phase of the promotion
+	Phase PromotionPhase `json:"phase,omitempty"`
+
+	// ObservedGeneration the generation of the Promotion the status refers to
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// StartTime the time the promotion started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime the time the promotion succeeded or failed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Environments the results of promoting to each environment
+	Environments []PromotionEnvironmentStatus `json:"environments,omitempty"`
+
+	// Conditions the conditions of the promotion
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// PromotionEnvironmentStatus the result of promoting to an environment
+type PromotionEnvironmentStatus struct {
+	// Name the name of the environment
+	Name string `json:"name"`
+
+	// State the state of the promotion to the environment such as 'merged' or 'failed'
+	State string `json:"state,omitempty"`
+
+	// PullRequestURL the URL of the promotion Pull Request
+	PullRequestURL string `json:"pullRequestURL,omitempty"`
+
+	// MergeSHA the merge commit SHA once the Pull Request merged
+	MergeSHA string `json:"mergeSHA,omitempty"`
+
+	// PipelineActivity the name of the PipelineActivity recording the promotion
+	PipelineActivity string `json:"pipelineActivity,omitempty"`
+}
+
+// PromotionList contains a list of Promotion
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PromotionList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Promotion `json:"items"`
+}
@@ -5,7 +5,6 @@ import (
 )
 
 // +genclient
-// +genclient:noStatus
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // Promote represents the boot configuration
@@ -19,6 +18,10 @@ type Promote struct {
 	// Spec holds the boot configuration
 	// +optional
 	Spec PromoteSpec `json:"spec"`
+
+	// Status holds the last promotions to each environment
+	// +optional
+	Status PromoteStatus `json:"status,omitempty"`
 }
 
 // PromoteSpec defines the desired state of Promote.
@@ -36,6 +39,159 @@ type PromoteSpec struct {
 
 	// KptRule specifies to fetch the apps resource via kpt : https://googlecontainertools.github.io/kpt/
 	KptRule *KptRule `json:"kptRule,omitempty"`
+
+	// Environments specifies settings for how to promote to specific environments. The settings for the Pull Request
+	// such as DirectPush, RequiredChecks and SmokeChecks are read from the '.jx/promote.yaml' file in the environment
+	// git repository. The settings which decide if and when to promote such as PromotionWindow, Freezes, FreezeAction
+	// and Upstreams are read from the '.jx/promote.yaml' file in the development environment git repository
+	Environments []PromoteEnvironment `json:"environments,omitempty"`
+
+	// Policies the policies evaluated before promoting. These settings are read from the '.jx/promote.yaml' file in
+	// the development environment git repository
+	Policies []PromotePolicy `json:"policies,omitempty"`
+
+	// Notifiers the notifications to send for promotion events. These settings are read from the '.jx/promote.yaml'
+	// file in the development environment git repository
+	Notifiers []Notifier `json:"notifiers,omitempty"`
+}
+
+// Notifier specifies where to send notifications of promotion events
+type Notifier struct {
+	// Name the optional name of the notifier used in log messages
+	Name string `json:"name,omitempty"`
+
+	// Kind the kind of notifier. Either 'webhook' to post the event as JSON, 'slack' for a Slack compatible incoming
+	// webhook or 'teams' for a Microsoft Teams incoming webhook. Defaults to 'webhook'
+	Kind string `json:"kind,omitempty"`
+
+	// URL the URL to post notifications to. Environment variables such as '${SLACK_WEBHOOK_URL}' are expanded so
+	// that the URL can be stored in a secret
+	URL string `json:"url"`
+
+	// Events the events to notify such as 'pr-created', 'pr-merged', 'merge-failed', 'pr-closed', 'timed-out' or
+	// 'update-completed'. Defaults to all events
+	Events []string `json:"events,omitempty"`
+
+	// Template the optional go template of the message. Can use {{.App}}, {{.Version}}, {{.Environment}},
+	// {{.Event}}, {{.Description}}, {{.PullRequestURL}}, {{.PullRequestNumber}}, {{.MergeSHA}} and {{.Reason}}
+	Template string `json:"template,omitempty"`
+
+	// Templates the optional go templates of the message for specific events which override the Template
+	Templates map[string]string `json:"templates,omitempty"`
+}
+
+// PromotePolicy specifies a CEL expression evaluated before promoting to decide if the promotion is allowed.
+//
+// The expression can use the variables 'app', 'version', 'environment', 'labels' (a map of the release labels),
+// 'author' and 'time' (a timestamp). It can return a bool where true allows the promotion and false results in
+// the Action or it can return one of the strings 'allow', 'deny' or 'require-manual'. Use the 'in' operator to check
+// a label exists such as: "security-scan" in labels && labels["security-scan"] == "passed"
+type PromotePolicy struct {
+	// Name the name of the policy which is logged with each decision
+	Name string `json:"name"`
+
+	// Expression the CEL expression to evaluate
+	Expression string `json:"expression"`
+
+	// Environments the names of the environments the policy applies to. Defaults to all environments
+	Environments []string `json:"environments,omitempty"`
+
+	// Action the result if the expression returns false. Either 'deny' or 'require-manual' to create a draft
+	// Pull Request. Defaults to 'deny'
+	Action string `json:"action,omitempty"`
+
+	// Message the optional message describing why the promotion is not allowed
+	Message string `json:"message,omitempty"`
+}
+
+// PromoteEnvironment specifies how to promote to an environment
+type PromoteEnvironment struct {
+	// Name the name of the environment. If blank these settings are the default for all environments
+	Name string `json:"name,omitempty"`
+
+	// DirectPush if enabled the promotion commit is pushed directly to the base branch of the environment
+	// repository rather than creating a Pull Request. Useful for low risk environments which do not need review.
+	// This setting is read from the '.jx/promote.yaml' file in the environment git repository
+	DirectPush bool `json:"directPush,omitempty"`
+
+	// RequiredChecks the names of the commit status checks which must succeed before the promotion Pull Request
+	// is merged. If specified any other checks are ignored. These settings are read from the '.jx/promote.yaml' file
+	// in the environment git repository
+	RequiredChecks []string `json:"requiredChecks,omitempty"`
+
+	// SmokeChecks the HTTP checks to run against the application once it has been promoted to the environment. These
+	// settings are read from the '.jx/promote.yaml' file in the environment git repository
+	SmokeChecks []SmokeCheck `json:"smokeChecks,omitempty"`
+
+	// PromotionWindow the optional times when promotions to the environment are allowed. These settings are
+	// read from the '.jx/promote.yaml' file in the development environment git repository
+	PromotionWindow *PromotionWindow `json:"promotionWindow,omitempty"`
+
+	// Freezes the change freeze periods when promotions to the environment are not allowed. These settings are
+	// read from the '.jx/promote.yaml' file in the development environment git repository
+	Freezes []FreezePeriod `json:"freezes,omitempty"`
+
+	// FreezeAction what to do when promoting outside of the promotion window or during a freeze. Either 'deny' to
+	// fail the promotion or 'draft' to create a draft Pull Request with the 'do-not-merge/hold' label. Defaults to 'deny'.
+	// This setting is read from the '.jx/promote.yaml' file in the development environment git repository
+	FreezeAction string `json:"freezeAction,omitempty"`
+
+	// Upstreams the names of the environments which must already have the version being promoted such as 'staging'
+	// for a 'production' environment. These settings are read from the '.jx/promote.yaml' file in the development
+	// environment git repository
+	Upstreams []string `json:"upstreams,omitempty"`
+}
+
+// PromotionWindow specifies the days and times when promotions are allowed
+type PromotionWindow struct {
+	// Timezone the IANA name of the time zone of the window such as 'Europe/London'. Defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
+
+	// Days the days of the week promotions are allowed such as 'Mon' or 'Monday'. Defaults to every day
+	Days []string `json:"days,omitempty"`
+
+	// StartTime the time of day promotions are allowed from in the form 'HH:MM'. Defaults to 00:00
+	StartTime string `json:"startTime,omitempty"`
+
+	// EndTime the time of day promotions are allowed until in the form 'HH:MM'. Defaults to the end of the day
+	EndTime string `json:"endTime,omitempty"`
+}
+
+// FreezePeriod specifies a period of time when promotions are not allowed
+type FreezePeriod struct {
+	// Name the name of the freeze such as 'holidays'
+	Name string `json:"name,omitempty"`
+
+	// Start the start of the freeze in RFC 3339 format such as '2024-12-20T17:00:00Z'
+	Start string `json:"start"`
+
+	// End the end of the freeze in RFC 3339 format such as '2025-01-02T09:00:00Z'
+	End string `json:"end"`
+
+	// Reason the optional reason for the freeze
+	Reason string `json:"reason,omitempty"`
+}
+
+// SmokeCheck specifies a HTTP request to make against a promoted application to verify it is working
+type SmokeCheck struct {
+	// Name the optional name of the check used in log messages
+	Name string `json:"name,omitempty"`
+
+	// URL the go template of the URL to request. Can use {{.URL}} for the discovered URL of the application along with
+	// {{.App}}, {{.Version}}, {{.Environment}} and {{.Namespace}}. Defaults to {{.URL}}
+	URL string `json:"url,omitempty"`
+
+	// ExpectedStatus the expected HTTP status code. Defaults to 200
+	ExpectedStatus int `json:"expectedStatus,omitempty"`
+
+	// BodyRegex the optional regular expression the response body must match
+	BodyRegex string `json:"bodyRegex,omitempty"`
+
+	// Retries the number of times to retry the check if it fails. Defaults to 3
+	Retries *int `json:"retries,omitempty"`
+
+	// RetryInterval the duration to wait between retries such as 10s. Defaults to 5s
+	RetryInterval string `json:"retryInterval,omitempty"`
 }
 
 // HelmRule specifies which chart to add the app to the Chart's 'requirements.yaml' file
@@ -96,6 +252,61 @@ type LineMatcher struct {
 	Regex string `json:"regex,omitempty"`
 }
 
+// PromoteStatus records the last promotions to each environment so that a Promote resource in the development
+// namespace can be queried for the promotion state
+type PromoteStatus struct {
+	// Environments the last promotions to each environment
+	Environments []PromoteEnvironmentStatus `json:"environments,omitempty"`
+}
+
+// PromoteEnvironmentStatus the last promotions of the apps to an environment
+type PromoteEnvironmentStatus struct {
+	// Name the name of the environment
+	Name string `json:"name"`
+
+	// Apps the last promotion of each app to the environment
+	Apps []PromoteAppStatus `json:"apps,omitempty"`
+}
+
+// PromoteAppStatus the last promotion of an app to an environment
+type PromoteAppStatus struct {
+	// Name the name of the app
+	Name string `json:"name"`
+
+	// Version the last version of the app promoted
+	Version string `json:"version,omitempty"`
+
+	// State the state of the promotion such as 'merged' or 'failed'
+	State string `json:"state,omitempty"`
+
+	// PullRequestURL the URL of the promotion Pull Request
+	PullRequestURL string `json:"pullRequestURL,omitempty"`
+
+	// MergeSHA the merge commit SHA once the Pull Request merged
+	MergeSHA string `json:"mergeSHA,omitempty"`
+
+	// PipelineActivity the name of the PipelineActivity recording the promotion
+	PipelineActivity string `json:"pipelineActivity,omitempty"`
+
+	// PromotionTime the time the promotion completed
+	PromotionTime *metav1.Time `json:"promotionTime,omitempty"`
+
+	// Policies the outcomes of the policies evaluated before promoting
+	Policies []PromotePolicyStatus `json:"policies,omitempty"`
+}
+
+// PromotePolicyStatus the outcome of evaluating a policy before promoting
+type PromotePolicyStatus struct {
+	// Name the name of the policy
+	Name string `json:"name"`
+
+	// Result either 'allow', 'deny' or 'require-manual'
+	Result string `json:"result"`
+
+	// Message the optional message of the policy describing the result
+	Message string `json:"message,omitempty"`
+}
+
 // PromoteList contains a list of Promote
 //
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
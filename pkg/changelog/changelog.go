@@ -0,0 +1,176 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+)
+
+const (
+	// commitSeparator separates each commit in the output of git log
+	commitSeparator = "---jx-promote-commit---"
+
+	// fieldSeparator separates the fields of a commit in the output of git log
+	fieldSeparator = "---jx-promote-field---"
+)
+
+var (
+	conventionalCommitRegex = regexp.MustCompile(`^(\w+)(\(([^)]*)\))?(!)?:\s*(.+)$`)
+	issueRegex              = regexp.MustCompile(`(^|[^\w/&])#(\d+)\b`)
+)
+
+// Commit represents a git commit parsed using the conventional commit format
+type Commit struct {
+	// SHA the commit SHA
+	SHA string
+
+	// Type the conventional commit type such as 'feat' or 'fix'. Empty if the message is not a conventional commit
+	Type string
+
+	// Scope the optional conventional commit scope
+	Scope string
+
+	// Description the description of the commit
+	Description string
+
+	// Breaking whether this commit is a breaking change
+	Breaking bool
+}
+
+// Group a group of commits of a particular kind in the changelog
+type Group struct {
+	Title string
+	Types []string
+}
+
+// Groups the default groups of conventional commit types used in the changelog in order
+var Groups = []Group{
+	{Title: "Breaking Changes"},
+	{Title: "New Features", Types: []string{"feat"}},
+	{Title: "Bug Fixes", Types: []string{"fix"}},
+	{Title: "Performance Improvements", Types: []string{"perf"}},
+	{Title: "Code Refactoring", Types: []string{"refactor"}},
+	{Title: "Documentation", Types: []string{"docs"}},
+	{Title: "Chores", Types: []string{"chore", "build", "ci", "test", "style", "revert"}},
+	{Title: "Other Changes"},
+}
+
+// ParseCommit parses the commit message
+func ParseCommit(sha, message string) *Commit {
+	message = strings.TrimSpace(message)
+	lines := strings.SplitN(message, "\n", 2)
+	subject := strings.TrimSpace(lines[0])
+	c := &Commit{
+		SHA:         sha,
+		Description: subject,
+	}
+	m := conventionalCommitRegex.FindStringSubmatch(subject)
+	if m != nil {
+		c.Type = strings.ToLower(m[1])
+		c.Scope = m[3]
+		c.Breaking = m[4] == "!"
+		c.Description = m[5]
+	}
+	if len(lines) > 1 && strings.Contains(lines[1], "BREAKING CHANGE") {
+		c.Breaking = true
+	}
+	return c
+}
+
+// ResolveTag returns the git tag for the given version in the given directory or an empty string if there is none.
+// Both the 'v' prefixed tag and the plain version are tried
+func ResolveTag(gitter gitclient.Interface, dir, version string) string {
+	if version == "" {
+		return ""
+	}
+	for _, tag := range []string{"v" + version, version} {
+		_, err := gitter.Command(dir, "rev-parse", "--verify", "--quiet", "refs/tags/"+tag)
+		if err == nil {
+			return tag
+		}
+	}
+	return ""
+}
+
+// LoadCommits loads the commits which are reachable from the toRef but not from the fromRef
+func LoadCommits(gitter gitclient.Interface, dir, fromRef, toRef string) ([]*Commit, error) {
+	text, err := gitter.Command(dir, "log", "--no-merges", "--format="+commitSeparator+"%H"+fieldSeparator+"%B", fromRef+".."+toRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find commits between %s and %s in dir %s: %w", fromRef, toRef, dir, err)
+	}
+	var answer []*Commit
+	for _, entry := range strings.Split(text, commitSeparator) {
+		parts := strings.SplitN(entry, fieldSeparator, 2)
+		if len(parts) != 2 {
+			continue
+		}
+		answer = append(answer, ParseCommit(strings.TrimSpace(parts[0]), parts[1]))
+	}
+	return answer, nil
+}
+
+// Generate generates the markdown for the changelog of the given commits, grouped by the conventional commit type.
+// If a repository URL is specified then commits and issue references are linked
+func Generate(commits []*Commit, repoURL string) string {
+	repoURL = strings.TrimSuffix(strings.TrimSuffix(repoURL, "/"), ".git")
+
+	grouped := map[string][]*Commit{}
+	for _, c := range commits {
+		title := groupTitle(c)
+		grouped[title] = append(grouped[title], c)
+	}
+
+	buf := strings.Builder{}
+	for _, g := range Groups {
+		list := grouped[g.Title]
+		if len(list) == 0 {
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("### " + g.Title + "\n\n")
+		for _, c := range list {
+			buf.WriteString("* " + formatCommit(c, repoURL) + "\n")
+		}
+	}
+	return buf.String()
+}
+
+func groupTitle(c *Commit) string {
+	if c.Breaking {
+		return "Breaking Changes"
+	}
+	for _, g := range Groups {
+		for _, t := range g.Types {
+			if t == c.Type {
+				return g.Title
+			}
+		}
+	}
+	return "Other Changes"
+}
+
+func formatCommit(c *Commit, repoURL string) string {
+	text := c.Description
+	if repoURL != "" {
+		text = issueRegex.ReplaceAllString(text, "${1}[#${2}]("+repoURL+"/issues/${2})")
+	}
+	if c.Scope != "" {
+		text = "**" + c.Scope + ":** " + text
+	}
+	if c.SHA != "" {
+		sha := c.SHA
+		if len(sha) > 7 {
+			sha = sha[:7]
+		}
+		if repoURL != "" {
+			text += " ([" + sha + "](" + repoURL + "/commit/" + c.SHA + "))"
+		} else {
+			text += " (" + sha + ")"
+		}
+	}
+	return text
+}
@@ -0,0 +1,73 @@
+//go:build unit
+// +build unit
+
+package changelog_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/changelog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommit(t *testing.T) {
+	testCases := []struct {
+		message  string
+		expected changelog.Commit
+	}{
+		{
+			message:  "feat: add cheese",
+			expected: changelog.Commit{Type: "feat", Description: "add cheese"},
+		},
+		{
+			message:  "fix(ui): broken button #12",
+			expected: changelog.Commit{Type: "fix", Scope: "ui", Description: "broken button #12"},
+		},
+		{
+			message:  "feat!: remove the old API",
+			expected: changelog.Commit{Type: "feat", Description: "remove the old API", Breaking: true},
+		},
+		{
+			message:  "chore: tidy\n\nBREAKING CHANGE: renamed things",
+			expected: changelog.Commit{Type: "chore", Description: "tidy", Breaking: true},
+		},
+		{
+			message:  "Merge some stuff",
+			expected: changelog.Commit{Description: "Merge some stuff"},
+		},
+	}
+
+	for _, tc := range testCases {
+		actual := changelog.ParseCommit("", tc.message)
+		assert.Equal(t, tc.expected, *actual, "for message %s", tc.message)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	commits := []*changelog.Commit{
+		changelog.ParseCommit("1234567890", "fix: something broke fixes #5"),
+		changelog.ParseCommit("abcdef1234", "feat(api): a new thing"),
+		changelog.ParseCommit("", "chore: release 1.2.3"),
+		changelog.ParseCommit("", "whatever"),
+	}
+
+	actual := changelog.Generate(commits, "https://github.com/myorg/myapp.git")
+
+	expected := `### New Features
+
+* **api:** a new thing ([abcdef1](https://github.com/myorg/myapp/commit/abcdef1234))
+
+### Bug Fixes
+
+* something broke fixes [#5](https://github.com/myorg/myapp/issues/5) ([1234567](https://github.com/myorg/myapp/commit/1234567890))
+
+### Chores
+
+* release 1.2.3
+
+### Other Changes
+
+* whatever
+`
+	assert.Equal(t, expected, actual)
+}
@@ -0,0 +1,89 @@
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// TypePullRequestCreated the promotion Pull Request was created
+	TypePullRequestCreated = "dev.jenkins-x.promote.pr.created"
+
+	// TypePullRequestMerged the promotion Pull Request was merged or the promotion was pushed directly
+	TypePullRequestMerged = "dev.jenkins-x.promote.pr.merged"
+
+	// TypePullRequestFailed the promotion failed
+	TypePullRequestFailed = "dev.jenkins-x.promote.pr.failed"
+
+	// TypePullRequestCompleted the promotion was deployed to the environment
+	TypePullRequestCompleted = "dev.jenkins-x.promote.pr.completed"
+
+	// SpecVersion the version of the CloudEvents specification
+	SpecVersion = "1.0"
+)
+
+// Data the data of a promotion event
+type Data struct {
+	App              string `json:"app"`
+	Version          string `json:"version,omitempty"`
+	Environment      string `json:"environment"`
+	PullRequestURL   string `json:"pullRequestURL,omitempty"`
+	PipelineActivity string `json:"pipelineActivity,omitempty"`
+	MergeSHA         string `json:"mergeSHA,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+}
+
+// Emitter sends CloudEvents to a sink over HTTP using the binary content mode
+type Emitter struct {
+	// Sink the URL to send events to
+	Sink string
+
+	// Source the source of the events
+	Source string
+
+	// HTTPClient the optional HTTP client. Defaults to a client with a 30 second timeout
+	HTTPClient *http.Client
+}
+
+// Emit sends the event of the given type with the data to the sink
+func (e *Emitter) Emit(ctx context.Context, eventType string, data *Data) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Sink, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request to %s: %w", e.Sink, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("ce-specversion", SpecVersion)
+	req.Header.Set("ce-id", uuid.New().String())
+	req.Header.Set("ce-type", eventType)
+	req.Header.Set("ce-source", e.Source)
+	req.Header.Set("ce-time", time.Now().UTC().Format(time.RFC3339Nano))
+	if data.App != "" {
+		req.Header.Set("ce-subject", data.App)
+	}
+
+	httpClient := e.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send event %s to %s: %w", eventType, e.Sink, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("failed to send event %s to %s: status %d", eventType, e.Sink, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build unit
+// +build unit
+
+package cloudevents_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/cloudevents"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmit(t *testing.T) {
+	var header http.Header
+	data := &cloudevents.Data{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		err := json.NewDecoder(r.Body).Decode(data)
+		assert.NoError(t, err, "failed to decode event data")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	emitter := &cloudevents.Emitter{
+		Sink:       server.URL,
+		Source:     "/jx-promote/jx",
+		HTTPClient: server.Client(),
+	}
+	err := emitter.Emit(context.TODO(), cloudevents.TypePullRequestCreated, &cloudevents.Data{
+		App:              "myapp",
+		Version:          "1.2.3",
+		Environment:      "staging",
+		PullRequestURL:   "https://github.com/myorg/environment-staging/pull/5",
+		PipelineActivity: "myorg-myapp-main-3",
+	})
+	require.NoError(t, err, "failed to emit event")
+
+	assert.Equal(t, "1.0", header.Get("ce-specversion"))
+	assert.Equal(t, "dev.jenkins-x.promote.pr.created", header.Get("ce-type"))
+	assert.Equal(t, "/jx-promote/jx", header.Get("ce-source"))
+	assert.Equal(t, "myapp", header.Get("ce-subject"))
+	assert.NotEmpty(t, header.Get("ce-id"))
+	assert.NotEmpty(t, header.Get("ce-time"))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+
+	assert.Equal(t, "myapp", data.App)
+	assert.Equal(t, "1.2.3", data.Version)
+	assert.Equal(t, "staging", data.Environment)
+	assert.Equal(t, "https://github.com/myorg/environment-staging/pull/5", data.PullRequestURL)
+	assert.Equal(t, "myorg-myapp-main-3", data.PipelineActivity)
+}
+
+func TestEmitFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	emitter := &cloudevents.Emitter{Sink: server.URL, Source: "jx-promote", HTTPClient: server.Client()}
+	err := emitter.Emit(context.TODO(), cloudevents.TypePullRequestFailed, &cloudevents.Data{App: "myapp"})
+	require.Error(t, err, "should fail for a bad request")
+}
@@ -2,10 +2,17 @@ package cmd
 
 import (
 	"github.com/jenkins-x-plugins/jx-promote/pkg/promote"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/spf13/cobra"
 )
 
 // Main creates a command object for the command
 func Main() (*cobra.Command, *promote.Options) {
-	return promote.NewCmdPromote()
+	cmd, o := promote.NewCmdPromote()
+
+	// lets allow the application as an argument now there are sub commands
+	cmd.Args = cobra.ArbitraryArgs
+	cmd.AddCommand(cobras.SplitCommand(promote.NewCmdSync()))
+	cmd.AddCommand(cobras.SplitCommand(promote.NewCmdController()))
+	return cmd, o
 }
@@ -34,6 +34,9 @@ type EnvironmentContext struct {
 
 	// GitRepository the current name  of the current git repository we are in
 	GitRepository string
+
+	// DevDir the directory of the clone of the development environment git repository if it has been cloned
+	DevDir string
 }
 
 // TeamSettings returns the team settings for the current environment
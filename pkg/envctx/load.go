@@ -96,6 +96,7 @@ func (e *EnvironmentContext) LazyLoad(gclient gitclient.Interface, jxClient vers
 			return fmt.Errorf("failed to clone URL %s: %w", gitCloneURL, err)
 		}
 
+		e.DevDir = cloneDir
 		versionsDir := filepath.Join(cloneDir, "versionStream")
 		exists, err := files.DirExists(versionsDir)
 		if err != nil {
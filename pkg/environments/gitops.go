@@ -15,6 +15,7 @@ import (
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -67,6 +68,7 @@ func (o *EnvironmentPullRequestOptions) Create(gitURL, prDir string, labels []st
 		}
 	}
 
+	endClone := o.StartSpan("clone", attribute.String("git.url", cloneGitURLSafe))
 	var dir string
 	if len(o.SparseCheckoutPatterns) > 0 {
 		dir, err = gitclient.SparseCloneToDir(o.Gitter, cloneGitURL, "", true, o.SparseCheckoutPatterns...)
@@ -76,10 +78,12 @@ func (o *EnvironmentPullRequestOptions) Create(gitURL, prDir string, labels []st
 			log.Logger().Infof("checking out remote base branch %s from %s", o.BaseBranchName, gitURL)
 			err = gitclient.CheckoutRemoteBranch(o.Gitter, dir, o.BaseBranchName)
 			if err != nil {
+				endClone(err)
 				return nil, fmt.Errorf("failed to checkout remote branch %s from %s: %w", o.BaseBranchName, gitURL, err)
 			}
 		}
 	}
+	endClone(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to clone git URL %s: %w", cloneGitURLSafe, err)
 	}
@@ -92,6 +96,7 @@ func (o *EnvironmentPullRequestOptions) Create(gitURL, prDir string, labels []st
 	}
 
 	o.OutDir = dir
+	o.PushedCommitSha = ""
 	log.Logger().Debugf("cloned %s to %s", termcolor.ColorInfo(cloneGitURLSafe), termcolor.ColorInfo(dir))
 
 	currentSha, err := gitclient.GetLatestCommitSha(o.Gitter, dir)
@@ -121,6 +126,19 @@ func (o *EnvironmentPullRequestOptions) Create(gitURL, prDir string, labels []st
 	}
 	o.Labels = maps.MapKeys(labelsSet)
 
+	if o.DirectPush {
+		if o.Fork {
+			return nil, fmt.Errorf("cannot push directly to the base branch of %s when using a fork", gitURL)
+		}
+		endPush := o.StartSpan("push", attribute.String("git.url", gitURL))
+		o.PushedCommitSha, err = o.PushToBaseBranch(dir, gitURL)
+		endPush(err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to push directly to %s: %w", gitURL, err)
+		}
+		return nil, nil
+	}
+
 	latestSha, err := gitclient.GetLatestCommitSha(o.Gitter, dir)
 	if err != nil {
 		return nil, fmt.Errorf("could not get current latest commit sha: %w", err)
@@ -138,7 +156,9 @@ func (o *EnvironmentPullRequestOptions) Create(gitURL, prDir string, labels []st
 		}
 	}
 
+	endCreate := o.StartSpan("create pull request", attribute.String("git.url", gitURL))
 	prInfo, err := o.CreatePullRequest(scmClient, gitURL, repoFullName, dir, doneCommit, existingPr)
+	endCreate(err)
 	if err != nil {
 		return prInfo, fmt.Errorf("failed to create pull request in dir %s: %w", dir, err)
 	}
@@ -43,7 +43,11 @@ func (o *EnvironmentPullRequestOptions) CreatePullRequest(scmClient *scm.Client,
 		log.Logger().Infof("no changes detected so not creating a Pull Request on %s", termcolor.ColorInfo(gitURL))
 		return nil, nil
 	}
-	changelogPrefix := fmt.Sprintf("\n# %s\n", o.Application)
+	// when promoting many apps the changelog already contains the prefix of each app
+	changelogPrefix := ""
+	if o.Application != "" {
+		changelogPrefix = ChangelogPrefix(o.Application)
+	}
 	existingChangelog := false
 
 	if existingPR != nil {
@@ -59,7 +63,8 @@ func (o *EnvironmentPullRequestOptions) CreatePullRequest(scmClient *scm.Client,
 			log.Logger().Infof("replacing commits of existing Pull Request %s", existingPR.Link)
 		}
 		parts := strings.SplitN(existingPR.Body, o.ChangelogSeparator, 2)
-		if len(parts) == 2 {
+		// a generated changelog already covers all of the changes so replaces any existing changelog
+		if len(parts) == 2 && !o.ReplaceChangelog {
 			changelogPrefix = parts[1]
 			existingChangelog = true
 		}
@@ -67,42 +72,9 @@ func (o *EnvironmentPullRequestOptions) CreatePullRequest(scmClient *scm.Client,
 	baseBranch := o.BaseBranchName
 
 	if existingPR == nil {
-		const headBranchPrefix = "HEAD branch:"
-		if baseBranch == "" {
-			if o.RemoteName == "" {
-				o.RemoteName = "origin"
-			}
-			text, err := gitter.Command(dir, "rev-parse", "--abbrev-ref", o.RemoteName+"/HEAD")
-			if err != nil {
-				text, err = gitter.Command(dir, "remote", "show", o.RemoteName)
-				if err != nil {
-					return nil, fmt.Errorf("failed to get the remote branch name for %s: %w", o.RemoteName, err)
-				}
-
-				lines := strings.Split(text, "\n")
-				for _, line := range lines {
-					line = strings.TrimSpace(line)
-					if strings.HasPrefix(line, headBranchPrefix) {
-						baseBranch = strings.TrimSpace(strings.TrimPrefix(line, headBranchPrefix))
-						if baseBranch != "" {
-							break
-						}
-					}
-				}
-				if baseBranch == "" {
-					return nil, fmt.Errorf("output of git remote show %s has no prefix %s as was: %s", o.RemoteName, headBranchPrefix, text)
-				}
-			} else {
-				text = strings.TrimSpace(text)
-				text = strings.TrimPrefix(text, o.RemoteName)
-				baseBranch = strings.TrimPrefix(text, "/")
-			}
-		}
-		if baseBranch == "" {
-			baseBranch, err = gitclient.Branch(gitter, dir)
-			if err != nil {
-				return nil, fmt.Errorf("failed to find branch in dir %s: %w", dir, err)
-			}
+		baseBranch, err = o.FindBaseBranch(dir)
+		if err != nil {
+			return nil, err
 		}
 		log.Logger().Debugf("creating Pull Request from %s branch", baseBranch)
 	}
@@ -180,6 +152,56 @@ func (o *EnvironmentPullRequestOptions) CreatePullRequest(scmClient *scm.Client,
 	return o.addLabelsToPullRequest(ctx, scmClient, repoFullName, pr)
 }
 
+// FindBaseBranch returns the base branch name if specified or finds the default branch of the remote
+func (o *EnvironmentPullRequestOptions) FindBaseBranch(dir string) (string, error) {
+	const headBranchPrefix = "HEAD branch:"
+	gitter := o.Git()
+	baseBranch := o.BaseBranchName
+	if baseBranch == "" {
+		if o.RemoteName == "" {
+			o.RemoteName = "origin"
+		}
+		text, err := gitter.Command(dir, "rev-parse", "--abbrev-ref", o.RemoteName+"/HEAD")
+		if err != nil {
+			text, err = gitter.Command(dir, "remote", "show", o.RemoteName)
+			if err != nil {
+				return "", fmt.Errorf("failed to get the remote branch name for %s: %w", o.RemoteName, err)
+			}
+
+			lines := strings.Split(text, "\n")
+			for _, line := range lines {
+				line = strings.TrimSpace(line)
+				if strings.HasPrefix(line, headBranchPrefix) {
+					baseBranch = strings.TrimSpace(strings.TrimPrefix(line, headBranchPrefix))
+					if baseBranch != "" {
+						break
+					}
+				}
+			}
+			if baseBranch == "" {
+				return "", fmt.Errorf("output of git remote show %s has no prefix %s as was: %s", o.RemoteName, headBranchPrefix, text)
+			}
+		} else {
+			text = strings.TrimSpace(text)
+			text = strings.TrimPrefix(text, o.RemoteName)
+			baseBranch = strings.TrimPrefix(text, "/")
+		}
+	}
+	if baseBranch == "" {
+		var err error
+		baseBranch, err = gitclient.Branch(gitter, dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to find branch in dir %s: %w", dir, err)
+		}
+	}
+	return baseBranch, nil
+}
+
+// ChangelogPrefix returns the prefix of the changelog of the given app in the pull request body
+func ChangelogPrefix(app string) string {
+	return fmt.Sprintf("\n# %s\n", app)
+}
+
 func (o *EnvironmentPullRequestOptions) GetScmClient(gitURL, kind string) (*scm.Client, string, error) {
 	if gitURL == "" {
 		log.Logger().Infof("no git URL specified so cannot create a Pull Request")
@@ -0,0 +1,97 @@
+package environments
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// DirectPushRetries the number of times to retry pushing directly to the base branch if the remote branch has changed
+var DirectPushRetries = 5
+
+// PushToBaseBranch commits the changes in the given dir and pushes them directly to the base branch rather than
+// creating a Pull Request. If the push is rejected because the base branch has changed we fetch the latest base branch,
+// re-run the change function on top of it and try again. Any other push failure is returned straight away. Returns the
+// SHA of the pushed commit or an empty string if there were no changes
+func (o *EnvironmentPullRequestOptions) PushToBaseBranch(dir, gitURL string) (string, error) {
+	gitter := o.Git()
+	if o.RemoteName == "" {
+		o.RemoteName = "origin"
+	}
+	baseBranch, err := o.FindBaseBranch(dir)
+	if err != nil {
+		return "", err
+	}
+	remoteBranch := o.RemoteName + "/" + baseBranch
+
+	_, _, err = gitclient.EnsureUserAndEmailSetup(gitter, dir, "", "")
+	if err != nil {
+		return "", fmt.Errorf("failed to setup git user and email: %w", err)
+	}
+
+	commitMessage := strings.TrimSpace(o.CommitTitle) + "\n\n" + o.CommitMessage
+	if o.CommitChangelog != "" {
+		prefix := ""
+		if o.Application != "" {
+			prefix = ChangelogPrefix(o.Application)
+		}
+		commitMessage += "\n\n" + o.ChangelogSeparator + prefix + "\n" + o.CommitChangelog
+	}
+
+	for i := 0; ; i++ {
+		_, err = gitclient.AddAndCommitFiles(gitter, dir, strings.TrimSpace(commitMessage))
+		if err != nil {
+			return "", fmt.Errorf("failed to commit changes in dir %s: %w", dir, err)
+		}
+		sha, err := gitclient.GetLatestCommitSha(gitter, dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to get the latest commit sha in dir %s: %w", dir, err)
+		}
+		remoteSha, err := gitter.Command(dir, "rev-parse", remoteBranch)
+		if err != nil {
+			return "", fmt.Errorf("failed to get the sha of %s in dir %s: %w", remoteBranch, dir, err)
+		}
+		if strings.TrimSpace(remoteSha) == sha {
+			log.Logger().Infof("no changes detected so not pushing to %s on %s", termcolor.ColorInfo(baseBranch), termcolor.ColorInfo(gitURL))
+			return "", nil
+		}
+
+		_, err = gitter.Command(dir, "push", o.RemoteName, "HEAD:"+baseBranch)
+		if err == nil {
+			log.Logger().Infof("Pushed commit %s directly to branch %s on %s", termcolor.ColorInfo(sha), termcolor.ColorInfo(baseBranch), termcolor.ColorInfo(gitURL))
+			return sha, nil
+		}
+		if !isNonFastForward(err) {
+			return "", fmt.Errorf("failed to push to branch %s on %s: %w", baseBranch, gitURL, err)
+		}
+		if i >= DirectPushRetries {
+			return "", fmt.Errorf("failed to push to branch %s on %s after %d attempts: %w", baseBranch, gitURL, i+1, err)
+		}
+		log.Logger().Warnf("failed to push to branch %s on %s so rebasing and trying again: %s", baseBranch, gitURL, err)
+
+		_, err = gitter.Command(dir, "fetch", o.RemoteName, baseBranch)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch branch %s in dir %s: %w", baseBranch, dir, err)
+		}
+		_, err = gitter.Command(dir, "reset", "--hard", remoteBranch)
+		if err != nil {
+			return "", fmt.Errorf("failed to reset to %s in dir %s: %w", remoteBranch, dir, err)
+		}
+
+		// lets re-run the rule against the latest base branch
+		err = o.Function()
+		if err != nil {
+			return "", fmt.Errorf("failed to invoke change function in dir %s: %w", dir, err)
+		}
+	}
+}
+
+// isNonFastForward returns true if the push was rejected because the remote branch has commits which are not in the
+// pushed commit
+func isNonFastForward(err error) bool {
+	text := err.Error()
+	return strings.Contains(text, "non-fast-forward") || strings.Contains(text, "(fetch first)")
+}
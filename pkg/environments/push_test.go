@@ -0,0 +1,98 @@
+//go:build unit
+// +build unit
+
+package environments_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushToBaseBranch(t *testing.T) {
+	gitter := cli.NewCLIClient("", nil)
+	tmpDir := t.TempDir()
+	remoteDir := filepath.Join(tmpDir, "remote.git")
+	_, err := gitter.Command(tmpDir, "init", "--bare", "--initial-branch=main", remoteDir)
+	require.NoError(t, err, "failed to create bare repository")
+
+	commitFile := func(dir, name, text string) {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o600)
+		require.NoError(t, err, "failed to write %s", name)
+		_, err = gitclient.AddAndCommitFiles(gitter, dir, "add "+name)
+		require.NoError(t, err, "failed to commit %s", name)
+	}
+
+	initDir := filepath.Join(tmpDir, "init")
+	_, err = gitter.Command(tmpDir, "clone", remoteDir, initDir)
+	require.NoError(t, err, "failed to clone")
+	_, _, err = gitclient.EnsureUserAndEmailSetup(gitter, initDir, "test", "test@example.com")
+	require.NoError(t, err)
+	_, err = gitter.Command(initDir, "checkout", "-b", "main")
+	require.NoError(t, err)
+	commitFile(initDir, "README.md", "hello")
+	_, err = gitter.Command(initDir, "push", "origin", "main")
+	require.NoError(t, err, "failed to push initial commit")
+
+	dir := filepath.Join(tmpDir, "env")
+	_, err = gitter.Command(tmpDir, "clone", remoteDir, dir)
+	require.NoError(t, err, "failed to clone")
+	_, _, err = gitclient.EnsureUserAndEmailSetup(gitter, dir, "test", "test@example.com")
+	require.NoError(t, err)
+
+	// lets change the remote branch so that the first push is rejected
+	commitFile(initDir, "other.txt", "other change")
+	_, err = gitter.Command(initDir, "push", "origin", "main")
+	require.NoError(t, err, "failed to push other commit")
+
+	invocations := 0
+	o := &environments.EnvironmentPullRequestOptions{
+		Gitter:         gitter,
+		BaseBranchName: "main",
+		CommitTitle:    "chore: promote myapp to version 1.2.3",
+		Function: func() error {
+			invocations++
+			return os.WriteFile(filepath.Join(dir, "version.txt"), []byte("1.2.3"), 0o600)
+		},
+	}
+	err = o.Function()
+	require.NoError(t, err)
+
+	sha, err := o.PushToBaseBranch(dir, remoteDir)
+	require.NoError(t, err, "failed to push to base branch")
+	require.NotEmpty(t, sha, "should have pushed a commit")
+	assert.Equal(t, 2, invocations, "should have re-run the function after rebasing")
+
+	remoteSha, err := gitter.Command(remoteDir, "rev-parse", "main")
+	require.NoError(t, err)
+	assert.Equal(t, sha, strings.TrimSpace(remoteSha), "remote main branch sha")
+
+	files, err := gitter.Command(remoteDir, "ls-tree", "--name-only", "main")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"README.md", "other.txt", "version.txt"}, strings.Fields(files), "files on main")
+
+	// pushing again with no changes should do nothing
+	sha, err = o.PushToBaseBranch(dir, remoteDir)
+	require.NoError(t, err)
+	assert.Empty(t, sha, "should not have pushed anything")
+
+	// lets make sure other push failures are not retried
+	hook := filepath.Join(remoteDir, "hooks", "pre-receive")
+	err = os.WriteFile(hook, []byte("#!/bin/sh\necho rejected by hook\nexit 1\n"), 0o700) //nolint:gosec
+	require.NoError(t, err, "failed to write %s", hook)
+
+	invocations = 0
+	o.CommitTitle = "chore: promote myapp to version 1.2.4"
+	err = os.WriteFile(filepath.Join(dir, "version.txt"), []byte("1.2.4"), 0o600)
+	require.NoError(t, err)
+	_, err = o.PushToBaseBranch(dir, remoteDir)
+	require.Error(t, err, "should fail when the push is rejected by a hook")
+	assert.Equal(t, 0, invocations, "should not have retried the push")
+}
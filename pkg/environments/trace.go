@@ -0,0 +1,24 @@
+package environments
+
+import (
+	"context"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartSpan starts a span as a child of the current span. The returned function ends the span, recording the error
+// if it is not nil, and restores the parent span
+func (o *EnvironmentPullRequestOptions) StartSpan(name string, attrs ...attribute.KeyValue) func(err error) {
+	parent := o.TraceContext
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, span := telemetry.Tracer().Start(parent, name, trace.WithAttributes(attrs...))
+	o.TraceContext = ctx
+	return func(err error) {
+		telemetry.End(span, err)
+		o.TraceContext = parent
+	}
+}
@@ -1,6 +1,8 @@
 package environments
 
 import (
+	"context"
+
 	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
 	"github.com/jenkins-x-plugins/jx-promote/pkg/envctx"
 	"github.com/jenkins-x/go-scm/scm"
@@ -51,6 +53,7 @@ type EnvironmentPullRequestOptions struct {
 	CommitMessage          string
 	CommitChangelog        string
 	ChangelogSeparator     string
+	ReplaceChangelog       bool
 	Namespace              string
 	JXClient               versioned.Interface
 	ScmClient              *scm.Client
@@ -60,6 +63,15 @@ type EnvironmentPullRequestOptions struct {
 	ReusePullRequest       bool
 	SparseCheckoutPatterns []string
 	Application            string
+
+	// DirectPush if enabled the changes are pushed directly to the base branch rather than creating a Pull Request
+	DirectPush bool
+
+	// PushedCommitSha the SHA of the commit pushed to the base branch if using DirectPush
+	PushedCommitSha string
+
+	// TraceContext the context of the current span used to trace the promotion
+	TraceContext context.Context
 }
 
 // A PullRequestFilter defines a filter for finding pull requests
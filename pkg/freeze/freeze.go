@@ -0,0 +1,172 @@
+package freeze
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+)
+
+const (
+	// ActionDeny fails the promotion when outside of the promotion window or during a freeze
+	ActionDeny = "deny"
+
+	// ActionDraft creates a draft Pull Request when outside of the promotion window or during a freeze
+	ActionDraft = "draft"
+)
+
+// Result the result of checking if promotions to an environment are frozen
+type Result struct {
+	// Frozen true if promotions are not currently allowed
+	Frozen bool
+
+	// Reason describes why promotions are not allowed
+	Reason string
+
+	// Action the action to take if promotions are frozen. Either ActionDeny or ActionDraft
+	Action string
+}
+
+// Check checks if promotions to the environment are frozen at the given time due to a freeze period or being outside
+// of the promotion window
+func Check(env *v1alpha1.PromoteEnvironment, now time.Time) (*Result, error) {
+	result := &Result{}
+	if env == nil {
+		return result, nil
+	}
+	result.Action = env.FreezeAction
+	switch result.Action {
+	case "":
+		result.Action = ActionDeny
+	case ActionDeny, ActionDraft:
+	default:
+		return nil, fmt.Errorf("unsupported freezeAction %s. Supported values: %s, %s", env.FreezeAction, ActionDeny, ActionDraft)
+	}
+
+	for i := range env.Freezes {
+		f := &env.Freezes[i]
+		start, err := time.Parse(time.RFC3339, f.Start)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse start %s of freeze %s: %w", f.Start, f.Name, err)
+		}
+		end, err := time.Parse(time.RFC3339, f.End)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse end %s of freeze %s: %w", f.End, f.Name, err)
+		}
+		if !now.Before(start) && now.Before(end) {
+			result.Frozen = true
+			result.Reason = fmt.Sprintf("change freeze %s until %s", f.Name, end.Format(time.RFC3339))
+			if f.Reason != "" {
+				result.Reason += ": " + f.Reason
+			}
+			return result, nil
+		}
+	}
+
+	w := env.PromotionWindow
+	if w == nil {
+		return result, nil
+	}
+	inWindow, err := InWindow(w, now)
+	if err != nil {
+		return nil, err
+	}
+	if !inWindow {
+		result.Frozen = true
+		result.Reason = "outside of the promotion window " + DescribeWindow(w)
+	}
+	return result, nil
+}
+
+// InWindow returns true if the time is within the promotion window
+func InWindow(w *v1alpha1.PromotionWindow, now time.Time) (bool, error) {
+	loc := time.UTC
+	if w.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(w.Timezone)
+		if err != nil {
+			return false, fmt.Errorf("failed to load timezone %s: %w", w.Timezone, err)
+		}
+	}
+	local := now.In(loc)
+
+	if len(w.Days) > 0 {
+		found := false
+		for _, d := range w.Days {
+			day, err := parseWeekday(d)
+			if err != nil {
+				return false, err
+			}
+			if day == local.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	minutes := local.Hour()*60 + local.Minute()
+	start := 0
+	if w.StartTime != "" {
+		var err error
+		start, err = parseTimeOfDay(w.StartTime)
+		if err != nil {
+			return false, err
+		}
+	}
+	end := 24 * 60
+	if w.EndTime != "" {
+		var err error
+		end, err = parseTimeOfDay(w.EndTime)
+		if err != nil {
+			return false, err
+		}
+	}
+	return minutes >= start && minutes < end, nil
+}
+
+// DescribeWindow returns a description of the promotion window
+func DescribeWindow(w *v1alpha1.PromotionWindow) string {
+	days := "every day"
+	if len(w.Days) > 0 {
+		days = strings.Join(w.Days, ", ")
+	}
+	start := w.StartTime
+	if start == "" {
+		start = "00:00"
+	}
+	end := w.EndTime
+	if end == "" {
+		end = "24:00"
+	}
+	tz := w.Timezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	return fmt.Sprintf("%s %s-%s %s", days, start, end, tz)
+}
+
+func parseWeekday(text string) (time.Weekday, error) {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if lower == name || lower == name[0:3] {
+			return d, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("invalid day of the week %s", text)
+}
+
+func parseTimeOfDay(text string) (int, error) {
+	if text == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %s should be in the form HH:MM: %w", text, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
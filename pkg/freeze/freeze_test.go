@@ -0,0 +1,83 @@
+//go:build unit
+// +build unit
+
+package freeze_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/freeze"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	env := &v1alpha1.PromoteEnvironment{
+		PromotionWindow: &v1alpha1.PromotionWindow{
+			Timezone:  "Europe/London",
+			Days:      []string{"Mon", "Tuesday", "wed", "Thu"},
+			StartTime: "09:00",
+			EndTime:   "16:30",
+		},
+		Freezes: []v1alpha1.FreezePeriod{
+			{
+				Name:   "holidays",
+				Start:  "2024-12-20T17:00:00Z",
+				End:    "2025-01-02T09:00:00Z",
+				Reason: "everyone is on holiday",
+			},
+		},
+		FreezeAction: freeze.ActionDraft,
+	}
+
+	testCases := []struct {
+		time   string
+		frozen bool
+		reason string
+	}{
+		{
+			// a Tuesday at 10:00 in London
+			time: "2024-06-11T09:00:00Z",
+		},
+		{
+			// a Tuesday at 16:30 in London
+			time:   "2024-06-11T15:30:00Z",
+			frozen: true,
+			reason: "outside of the promotion window Mon, Tuesday, wed, Thu 09:00-16:30 Europe/London",
+		},
+		{
+			// a Friday
+			time:   "2024-06-14T10:00:00Z",
+			frozen: true,
+			reason: "outside of the promotion window Mon, Tuesday, wed, Thu 09:00-16:30 Europe/London",
+		},
+		{
+			// a Monday in the holiday freeze
+			time:   "2024-12-30T10:00:00Z",
+			frozen: true,
+			reason: "change freeze holidays until 2025-01-02T09:00:00Z: everyone is on holiday",
+		},
+	}
+
+	for _, tc := range testCases {
+		now, err := time.Parse(time.RFC3339, tc.time)
+		require.NoError(t, err)
+
+		result, err := freeze.Check(env, now)
+		require.NoError(t, err, "for time %s", tc.time)
+		assert.Equal(t, tc.frozen, result.Frozen, "frozen for time %s", tc.time)
+		assert.Equal(t, tc.reason, result.Reason, "reason for time %s", tc.time)
+		assert.Equal(t, freeze.ActionDraft, result.Action, "action for time %s", tc.time)
+	}
+}
+
+func TestCheckNoSettings(t *testing.T) {
+	result, err := freeze.Check(nil, time.Now())
+	require.NoError(t, err)
+	assert.False(t, result.Frozen, "should not be frozen without settings")
+
+	_, err = freeze.Check(&v1alpha1.PromoteEnvironment{FreezeAction: "cheese"}, time.Now())
+	require.Error(t, err, "should fail for an invalid freeze action")
+}
@@ -0,0 +1,252 @@
+package lease
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// AnnotationGitURL the annotation on the Lease recording the git URL of the environment it locks
+	AnnotationGitURL = "jenkins-x.io/promote-git-url"
+
+	// LabelLock the label on the Leases used to lock environments
+	LabelLock = "jenkins-x.io/promote-lock"
+
+	// DefaultTTL the default duration of the lease before another holder can take it over unless it is renewed
+	DefaultTTL = 2 * time.Minute
+
+	// DefaultPollInterval the default interval between attempts to acquire a lease held by another holder
+	DefaultPollInterval = 5 * time.Second
+)
+
+// Lock a lock of an environment git repository using a Kubernetes Lease which is renewed while it is held
+type Lock struct {
+	KubeClient kubernetes.Interface
+	Namespace  string
+	GitURL     string
+
+	// Holder the identity of the holder of the lock
+	Holder string
+
+	// TTL the duration of the lease which expires if the holder stops renewing it
+	TTL time.Duration
+
+	// PollInterval the interval between attempts to acquire the lease
+	PollInterval time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	mu   sync.Mutex
+	lost chan struct{}
+	err  error
+}
+
+// Name returns the name of the Lease for the git URL
+func Name(gitURL string) string {
+	sum := sha256.Sum256([]byte(gitURL))
+	return "jx-promote-" + hex.EncodeToString(sum[:])[:16]
+}
+
+// Acquire waits until the lease is acquired or the context is done. Once acquired the lease is renewed in the
+// background until it is released
+func (l *Lock) Acquire(ctx context.Context) error {
+	if l.TTL <= 0 {
+		l.TTL = DefaultTTL
+	}
+	if l.PollInterval <= 0 {
+		l.PollInterval = DefaultPollInterval
+	}
+	name := Name(l.GitURL)
+	lastHolder := ""
+	for {
+		holder, err := l.tryAcquire(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to acquire Lease %s in namespace %s: %w", name, l.Namespace, err)
+		}
+		if holder == "" {
+			log.Logger().Infof("acquired the promotion lock %s for %s as %s", termcolor.ColorInfo(name), termcolor.ColorInfo(l.GitURL), termcolor.ColorInfo(l.Holder))
+			l.stop = make(chan struct{})
+			l.lost = make(chan struct{})
+			l.mu.Lock()
+			l.err = nil
+			l.mu.Unlock()
+			l.wg.Add(1)
+			go l.renew(name)
+			return nil
+		}
+		if holder != lastHolder {
+			log.Logger().Infof("waiting for the promotion lock %s for %s held by %s", termcolor.ColorInfo(name), termcolor.ColorInfo(l.GitURL), termcolor.ColorInfo(holder))
+			lastHolder = holder
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for the promotion lock %s for %s held by %s: %w", name, l.GitURL, holder, ctx.Err())
+		case <-time.After(l.PollInterval):
+		}
+	}
+}
+
+// tryAcquire attempts to create or take over the lease returning the current holder if it is held by another holder
+func (l *Lock) tryAcquire(ctx context.Context, name string) (string, error) {
+	leases := l.KubeClient.CoordinationV1().Leases(l.Namespace)
+	now := metav1.NewMicroTime(time.Now())
+	ttl := int32(l.TTL.Seconds())
+
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", err
+		}
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   l.Namespace,
+				Labels:      map[string]string{LabelLock: "true"},
+				Annotations: map[string]string{AnnotationGitURL: l.GitURL},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &l.Holder,
+				LeaseDurationSeconds: &ttl,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return "another holder", nil
+		}
+		return "", err
+	}
+
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+	if holder != "" && holder != l.Holder && !expired(lease, now.Time) {
+		return holder, nil
+	}
+	if holder != l.Holder {
+		lease.Spec.LeaseTransitions = increment(lease.Spec.LeaseTransitions)
+		if holder != "" {
+			log.Logger().Warnf("taking over the expired promotion lock %s from %s", name, holder)
+		}
+	}
+	lease.Spec.HolderIdentity = &l.Holder
+	lease.Spec.LeaseDurationSeconds = &ttl
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return "another holder", nil
+	}
+	return "", err
+}
+
+// renew renews the lease until the lock is released. The lock is lost if another holder takes over the lease or it
+// cannot be renewed within its TTL
+func (l *Lock) renew(name string) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.TTL / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			holder, err := l.tryAcquire(context.Background(), name)
+			if err != nil {
+				if time.Since(renewed) < l.TTL {
+					log.Logger().Warnf("failed to renew the promotion lock %s: %s", name, err.Error())
+					continue
+				}
+				l.lose(fmt.Errorf("failed to renew the promotion lock %s within %s: %w", name, l.TTL.String(), err))
+				return
+			}
+			if holder != "" {
+				l.lose(fmt.Errorf("lost the promotion lock %s to %s", name, holder))
+				return
+			}
+			renewed = time.Now()
+		}
+	}
+}
+
+// lose records why the lock was lost and signals the Lost channel
+func (l *Lock) lose(err error) {
+	log.Logger().Warnf("%s", err.Error())
+	l.mu.Lock()
+	l.err = err
+	l.mu.Unlock()
+	close(l.lost)
+}
+
+// Lost returns a channel which is closed if the lock is lost while it is held
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns why the lock was lost or nil if it is still held
+func (l *Lock) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Release stops renewing the lease and deletes it if it is still held by this holder
+func (l *Lock) Release(ctx context.Context) error {
+	if l.stop == nil {
+		return nil
+	}
+	close(l.stop)
+	l.wg.Wait()
+	l.stop = nil
+
+	name := Name(l.GitURL)
+	leases := l.KubeClient.CoordinationV1().Leases(l.Namespace)
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get Lease %s in namespace %s: %w", name, l.Namespace, err)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.Holder {
+		return nil
+	}
+	err = leases.Delete(ctx, name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion}})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete Lease %s in namespace %s: %w", name, l.Namespace, err)
+	}
+	log.Logger().Infof("released the promotion lock %s for %s", termcolor.ColorInfo(name), termcolor.ColorInfo(l.GitURL))
+	return nil
+}
+
+// expired returns true if the lease has not been renewed within its duration
+func expired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	ttl := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return lease.Spec.RenewTime.Add(ttl).Before(now)
+}
+
+func increment(value *int32) *int32 {
+	answer := int32(1)
+	if value != nil {
+		answer = *value + 1
+	}
+	return &answer
+}
@@ -0,0 +1,118 @@
+//go:build unit
+// +build unit
+
+package lease_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/lease"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLock(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	gitURL := "https://github.com/myorg/environment-staging.git"
+	name := lease.Name(gitURL)
+
+	first := &lease.Lock{KubeClient: kubeClient, Namespace: "jx", GitURL: gitURL, Holder: "first", PollInterval: time.Millisecond}
+	err := first.Acquire(context.TODO())
+	require.NoError(t, err, "failed to acquire the first lock")
+
+	l, err := kubeClient.CoordinationV1().Leases("jx").Get(context.TODO(), name, metav1.GetOptions{})
+	require.NoError(t, err, "failed to get Lease %s", name)
+	require.NotNil(t, l.Spec.HolderIdentity, "holder")
+	assert.Equal(t, "first", *l.Spec.HolderIdentity)
+	assert.Equal(t, gitURL, l.Annotations[lease.AnnotationGitURL])
+
+	second := &lease.Lock{KubeClient: kubeClient, Namespace: "jx", GitURL: gitURL, Holder: "second", PollInterval: time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	err = second.Acquire(ctx)
+	require.Error(t, err, "should not acquire a lock held by another holder")
+	assert.Contains(t, err.Error(), "held by first")
+
+	err = first.Release(context.TODO())
+	require.NoError(t, err, "failed to release the first lock")
+
+	err = second.Acquire(context.TODO())
+	require.NoError(t, err, "failed to acquire the released lock")
+	err = second.Release(context.TODO())
+	require.NoError(t, err, "failed to release the second lock")
+}
+
+func TestLockTakesOverExpiredLease(t *testing.T) {
+	gitURL := "https://github.com/myorg/environment-production.git"
+	holder := "crashed"
+	ttl := int32(60)
+	renewTime := metav1.NewMicroTime(time.Now().Add(-time.Hour))
+	kubeClient := fake.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      lease.Name(gitURL),
+			Namespace: "jx",
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &ttl,
+			RenewTime:            &renewTime,
+		},
+	})
+
+	lock := &lease.Lock{KubeClient: kubeClient, Namespace: "jx", GitURL: gitURL, Holder: "me"}
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	err := lock.Acquire(ctx)
+	require.NoError(t, err, "should take over an expired lease")
+
+	l, err := kubeClient.CoordinationV1().Leases("jx").Get(context.TODO(), lease.Name(gitURL), metav1.GetOptions{})
+	require.NoError(t, err, "failed to get Lease")
+	assert.Equal(t, "me", *l.Spec.HolderIdentity)
+	require.NotNil(t, l.Spec.LeaseTransitions, "lease transitions")
+	assert.Equal(t, int32(1), *l.Spec.LeaseTransitions)
+
+	err = lock.Release(context.TODO())
+	require.NoError(t, err, "failed to release the lock")
+}
+
+func TestLockLost(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	gitURL := "https://github.com/myorg/environment-staging.git"
+	name := lease.Name(gitURL)
+
+	lock := &lease.Lock{KubeClient: kubeClient, Namespace: "jx", GitURL: gitURL, Holder: "me", TTL: 30 * time.Millisecond}
+	err := lock.Acquire(context.TODO())
+	require.NoError(t, err, "failed to acquire the lock")
+	require.NoError(t, lock.Err(), "should hold the lock")
+
+	// lets simulate another holder taking over the lease
+	l, err := kubeClient.CoordinationV1().Leases("jx").Get(context.TODO(), name, metav1.GetOptions{})
+	require.NoError(t, err, "failed to get Lease %s", name)
+	holder := "other"
+	ttl := int32(60)
+	renewTime := metav1.NewMicroTime(time.Now())
+	l.Spec.HolderIdentity = &holder
+	l.Spec.LeaseDurationSeconds = &ttl
+	l.Spec.RenewTime = &renewTime
+	_, err = kubeClient.CoordinationV1().Leases("jx").Update(context.TODO(), l, metav1.UpdateOptions{})
+	require.NoError(t, err, "failed to update Lease %s", name)
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "should lose the lock taken over by another holder")
+	}
+	require.Error(t, lock.Err(), "should have lost the lock")
+	assert.Contains(t, lock.Err().Error(), "lost the promotion lock")
+
+	err = lock.Release(context.TODO())
+	require.NoError(t, err, "failed to release the lost lock")
+	l, err = kubeClient.CoordinationV1().Leases("jx").Get(context.TODO(), name, metav1.GetOptions{})
+	require.NoError(t, err, "should not delete the Lease of another holder")
+	assert.Equal(t, "other", *l.Spec.HolderIdentity)
+}
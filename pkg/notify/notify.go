@@ -0,0 +1,222 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	// EventPullRequestCreated the promotion Pull Request was created
+	EventPullRequestCreated = "pr-created"
+
+	// EventPullRequestMerged the promotion Pull Request was merged or the promotion was pushed directly
+	EventPullRequestMerged = "pr-merged"
+
+	// EventMergeFailed the promotion Pull Request could not be merged
+	EventMergeFailed = "merge-failed"
+
+	// EventPullRequestClosed the promotion Pull Request was closed without merging
+	EventPullRequestClosed = "pr-closed"
+
+	// EventTimedOut the promotion timed out
+	EventTimedOut = "timed-out"
+
+	// EventUpdateCompleted the promotion was deployed to the environment
+	EventUpdateCompleted = "update-completed"
+
+	// KindWebhook posts the event as JSON
+	KindWebhook = "webhook"
+
+	// KindSlack posts the message to a Slack compatible incoming webhook
+	KindSlack = "slack"
+
+	// KindTeams posts the message to a Microsoft Teams incoming webhook
+	KindTeams = "teams"
+
+	// DefaultTemplate the default template of notification messages
+	DefaultTemplate = "Promotion of {{.App}} version {{.Version}} to {{.Environment}} {{.Description}}{{if .PullRequestURL}} {{.PullRequestURL}}{{end}}{{if .Reason}}: {{.Reason}}{{end}}"
+)
+
+var descriptions = map[string]string{
+	EventPullRequestCreated: "created a Pull Request",
+	EventPullRequestMerged:  "was merged",
+	EventMergeFailed:        "failed to merge",
+	EventPullRequestClosed:  "was closed without merging",
+	EventTimedOut:           "timed out",
+	EventUpdateCompleted:    "completed",
+}
+
+// Event a promotion lifecycle event
+type Event struct {
+	Event             string    `json:"event"`
+	App               string    `json:"app"`
+	Version           string    `json:"version,omitempty"`
+	Environment       string    `json:"environment"`
+	PullRequestURL    string    `json:"pullRequestURL,omitempty"`
+	PullRequestNumber int       `json:"pullRequestNumber,omitempty"`
+	MergeSHA          string    `json:"mergeSHA,omitempty"`
+	Reason            string    `json:"reason,omitempty"`
+	Time              time.Time `json:"time"`
+	Message           string    `json:"message,omitempty"`
+}
+
+// Description returns a description of the event
+func (e *Event) Description() string {
+	answer := descriptions[e.Event]
+	if answer == "" {
+		return e.Event
+	}
+	return answer
+}
+
+// Notifier sends notifications of promotion events
+type Notifier interface {
+	// Notify sends the event with the message
+	Notify(ctx context.Context, event *Event) error
+}
+
+// NotifierFunc a function which implements Notifier
+type NotifierFunc func(ctx context.Context, event *Event) error
+
+// Notify sends the event
+func (f NotifierFunc) Notify(ctx context.Context, event *Event) error {
+	return f(ctx, event)
+}
+
+// New creates a notifier for the configuration
+func New(config *v1alpha1.Notifier, httpClient *http.Client) (Notifier, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	u := os.ExpandEnv(config.URL)
+	if u == "" {
+		return nil, fmt.Errorf("no url for notifier %s", config.Name)
+	}
+	var payload func(*Event) interface{}
+	switch config.Kind {
+	case "", KindWebhook:
+		payload = func(e *Event) interface{} {
+			return e
+		}
+	case KindSlack:
+		payload = func(e *Event) interface{} {
+			return map[string]string{"text": e.Message}
+		}
+	case KindTeams:
+		payload = func(e *Event) interface{} {
+			return map[string]string{
+				"@type":    "MessageCard",
+				"@context": "https://schema.org/extensions",
+				"summary":  e.Message,
+				"text":     e.Message,
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kind %s of notifier %s. Supported values: %s, %s, %s", config.Kind, config.Name, KindWebhook, KindSlack, KindTeams)
+	}
+	return NotifierFunc(func(ctx context.Context, event *Event) error {
+		return post(ctx, httpClient, u, payload(event))
+	}), nil
+}
+
+// Message evaluates the template of the notifier for the event
+func Message(config *v1alpha1.Notifier, event *Event) (string, error) {
+	text := config.Templates[event.Event]
+	if text == "" {
+		text = config.Template
+	}
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", text, err)
+	}
+	buf := &strings.Builder{}
+	err = tmpl.Execute(buf, event)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate template %s: %w", text, err)
+	}
+	return buf.String(), nil
+}
+
+// Matches returns true if the notifier should be notified of the event
+func Matches(config *v1alpha1.Notifier, event string) bool {
+	if len(config.Events) == 0 {
+		return true
+	}
+	for _, e := range config.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NotifyAll sends the event to all the configured notifiers which match the event. Failures are logged rather than
+// failing the promotion
+func NotifyAll(ctx context.Context, configs []v1alpha1.Notifier, httpClient *http.Client, event *Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for i := range configs {
+		config := &configs[i]
+		if !Matches(config, event.Event) {
+			continue
+		}
+		name := config.Name
+		if name == "" {
+			name = config.Kind
+		}
+		n, err := New(config, httpClient)
+		if err != nil {
+			log.Logger().Warnf("failed to create notifier %s: %s", name, err.Error())
+			continue
+		}
+		e := *event
+		e.Message, err = Message(config, &e)
+		if err != nil {
+			log.Logger().Warnf("failed to create the message for notifier %s: %s", name, err.Error())
+			continue
+		}
+		err = n.Notify(ctx, &e)
+		if err != nil {
+			log.Logger().Warnf("failed to notify %s of %s: %s", name, e.Event, err.Error())
+			continue
+		}
+		log.Logger().Debugf("notified %s of %s", name, e.Event)
+	}
+}
+
+func post(ctx context.Context, httpClient *http.Client, u string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post notification: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("notification failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+//go:build unit
+// +build unit
+
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyAll(t *testing.T) {
+	payloads := map[string][]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]interface{}{}
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		assert.NoError(t, err, "failed to decode payload for %s", r.URL.Path)
+		payloads[r.URL.Path] = append(payloads[r.URL.Path], payload)
+	}))
+	defer server.Close()
+
+	t.Setenv("TEST_SLACK_PATH", "/slack")
+	configs := []v1alpha1.Notifier{
+		{
+			Name: "webhook",
+			URL:  server.URL + "/webhook",
+		},
+		{
+			Name:   "slack",
+			Kind:   notify.KindSlack,
+			URL:    server.URL + "${TEST_SLACK_PATH}",
+			Events: []string{notify.EventPullRequestMerged},
+		},
+		{
+			Name:     "teams",
+			Kind:     notify.KindTeams,
+			URL:      server.URL + "/teams",
+			Template: "{{.App}} {{.Event}}",
+			Templates: map[string]string{
+				notify.EventTimedOut: "{{.App}} is taking too long: {{.Reason}}",
+			},
+		},
+	}
+
+	notify.NotifyAll(context.TODO(), configs, server.Client(), &notify.Event{
+		Event:          notify.EventPullRequestMerged,
+		App:            "myapp",
+		Version:        "1.2.3",
+		Environment:    "staging",
+		PullRequestURL: "https://github.com/myorg/environment-staging/pull/5",
+	})
+	notify.NotifyAll(context.TODO(), configs, server.Client(), &notify.Event{
+		Event:       notify.EventTimedOut,
+		App:         "myapp",
+		Environment: "staging",
+		Reason:      "waited 1h",
+	})
+
+	require.Len(t, payloads["/webhook"], 2, "webhook payloads")
+	assert.Equal(t, "pr-merged", payloads["/webhook"][0]["event"])
+	assert.Equal(t, "1.2.3", payloads["/webhook"][0]["version"])
+	assert.Equal(t, "Promotion of myapp version 1.2.3 to staging was merged https://github.com/myorg/environment-staging/pull/5", payloads["/webhook"][0]["message"])
+
+	require.Len(t, payloads["/slack"], 1, "slack should only be notified of merges")
+	assert.Equal(t, "Promotion of myapp version 1.2.3 to staging was merged https://github.com/myorg/environment-staging/pull/5", payloads["/slack"][0]["text"])
+
+	require.Len(t, payloads["/teams"], 2, "teams payloads")
+	assert.Equal(t, "MessageCard", payloads["/teams"][0]["@type"])
+	assert.Equal(t, "myapp pr-merged", payloads["/teams"][0]["text"])
+	assert.Equal(t, "myapp is taking too long: waited 1h", payloads["/teams"][1]["text"])
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := notify.New(&v1alpha1.Notifier{Name: "cheese", Kind: "cheese", URL: "https://example.com"}, nil)
+	require.Error(t, err, "should fail for an unsupported kind")
+
+	_, err = notify.New(&v1alpha1.Notifier{Name: "empty"}, nil)
+	require.Error(t, err, "should fail without a URL")
+}
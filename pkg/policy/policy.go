@@ -0,0 +1,176 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+)
+
+const (
+	// ResultAllow the promotion is allowed
+	ResultAllow = "allow"
+
+	// ResultDeny the promotion is not allowed
+	ResultDeny = "deny"
+
+	// ResultRequireManual the promotion requires a manual approval so a draft Pull Request is created
+	ResultRequireManual = "require-manual"
+)
+
+// Input the details of a promotion that policies are evaluated against
+type Input struct {
+	// App the name of the app being promoted
+	App string
+
+	// Version the version being promoted
+	Version string
+
+	// Environment the name of the environment being promoted to
+	Environment string
+
+	// Labels the labels of the release being promoted
+	Labels map[string]string
+
+	// Author the author of the change being promoted
+	Author string
+
+	// Time the time of the promotion
+	Time time.Time
+}
+
+// Decision the result of evaluating a policy
+type Decision struct {
+	// App the name of the app the policy was evaluated for
+	App string
+
+	// Policy the name of the policy
+	Policy string
+
+	// Result either ResultAllow, ResultDeny or ResultRequireManual
+	Result string
+
+	// Message the optional message of the policy describing the decision
+	Message string
+}
+
+// Evaluate evaluates the policies which apply to the environment of the input and returns their decisions
+func Evaluate(policies []v1alpha1.PromotePolicy, input *Input) ([]Decision, error) {
+	env, err := NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	activation := map[string]interface{}{
+		"app":         input.App,
+		"version":     input.Version,
+		"environment": input.Environment,
+		"labels":      input.Labels,
+		"author":      input.Author,
+		"time":        input.Time,
+	}
+	if input.Labels == nil {
+		activation["labels"] = map[string]string{}
+	}
+
+	var decisions []Decision
+	for i := range policies {
+		p := &policies[i]
+		if len(p.Environments) > 0 && !contains(p.Environments, input.Environment) {
+			continue
+		}
+		result, err := evaluate(env, p, activation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate policy %s: %w", p.Name, err)
+		}
+		decisions = append(decisions, Decision{
+			App:     input.App,
+			Policy:  p.Name,
+			Result:  result,
+			Message: p.Message,
+		})
+	}
+	return decisions, nil
+}
+
+// NewEnv creates the CEL environment for policy expressions
+func NewEnv() (*cel.Env, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("app", cel.StringType),
+		cel.Variable("version", cel.StringType),
+		cel.Variable("environment", cel.StringType),
+		cel.Variable("labels", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("author", cel.StringType),
+		cel.Variable("time", cel.TimestampType),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	return env, nil
+}
+
+// Combine combines the decisions into a single result. Any deny wins over require-manual which wins over allow
+func Combine(decisions []Decision) string {
+	answer := ResultAllow
+	for _, d := range decisions {
+		switch d.Result {
+		case ResultDeny:
+			return ResultDeny
+		case ResultRequireManual:
+			answer = ResultRequireManual
+		}
+	}
+	return answer
+}
+
+// evaluate evaluates the expression of the policy. A bool result of true allows the promotion and false results in
+// the action of the policy which defaults to deny. A string result must be one of the supported results
+func evaluate(env *cel.Env, p *v1alpha1.PromotePolicy, activation map[string]interface{}) (string, error) {
+	action := p.Action
+	switch action {
+	case "":
+		action = ResultDeny
+	case ResultDeny, ResultRequireManual:
+	default:
+		return "", fmt.Errorf("unsupported action %s. Supported values: %s, %s", p.Action, ResultDeny, ResultRequireManual)
+	}
+
+	ast, issues := env.Compile(p.Expression)
+	if issues != nil && issues.Err() != nil {
+		return "", fmt.Errorf("failed to compile expression %s: %w", p.Expression, issues.Err())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return "", fmt.Errorf("failed to create program for expression %s: %w", p.Expression, err)
+	}
+	value, _, err := prg.Eval(activation)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate expression %s: %w", p.Expression, err)
+	}
+
+	switch v := value.(type) {
+	case types.Bool:
+		if v {
+			return ResultAllow, nil
+		}
+		return action, nil
+	case types.String:
+		result := string(v)
+		switch result {
+		case ResultAllow, ResultDeny, ResultRequireManual:
+			return result, nil
+		}
+		return "", fmt.Errorf("expression %s returned unsupported result %s. Supported values: %s, %s, %s", p.Expression, result, ResultAllow, ResultDeny, ResultRequireManual)
+	}
+	return "", fmt.Errorf("expression %s should return a bool or string but returned %s", p.Expression, value.Type().TypeName())
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+//go:build unit
+// +build unit
+
+package policy_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	policies := []v1alpha1.PromotePolicy{
+		{
+			Name:         "security-scan",
+			Expression:   `"security-scan" in labels && labels["security-scan"] == "passed"`,
+			Environments: []string{"production"},
+			Message:      "apps must pass the security scan",
+		},
+		{
+			Name:       "weekend",
+			Expression: `time.getDayOfWeek("Europe/London") in [0, 6] ? "require-manual" : "allow"`,
+		},
+		{
+			Name:       "bots",
+			Expression: `!author.endsWith("[bot]")`,
+			Action:     policy.ResultRequireManual,
+		},
+	}
+
+	// a Tuesday
+	weekday, err := time.Parse(time.RFC3339, "2024-06-11T10:00:00Z")
+	require.NoError(t, err)
+	// a Saturday
+	weekend, err := time.Parse(time.RFC3339, "2024-06-15T10:00:00Z")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		input    policy.Input
+		results  []string
+		combined string
+	}{
+		{
+			name:     "staging",
+			input:    policy.Input{App: "myapp", Version: "1.2.3", Environment: "staging", Author: "jstrachan", Time: weekday},
+			results:  []string{"allow", "allow"},
+			combined: policy.ResultAllow,
+		},
+		{
+			name:     "production without scan",
+			input:    policy.Input{App: "myapp", Version: "1.2.3", Environment: "production", Author: "jstrachan", Time: weekday},
+			results:  []string{"deny", "allow", "allow"},
+			combined: policy.ResultDeny,
+		},
+		{
+			name:     "production with scan",
+			input:    policy.Input{App: "myapp", Version: "1.2.3", Environment: "production", Labels: map[string]string{"security-scan": "passed"}, Author: "jstrachan", Time: weekday},
+			results:  []string{"allow", "allow", "allow"},
+			combined: policy.ResultAllow,
+		},
+		{
+			name:     "weekend bot",
+			input:    policy.Input{App: "myapp", Version: "1.2.3", Environment: "staging", Author: "renovate[bot]", Time: weekend},
+			results:  []string{"require-manual", "require-manual"},
+			combined: policy.ResultRequireManual,
+		},
+	}
+
+	for _, tc := range testCases {
+		decisions, err := policy.Evaluate(policies, &tc.input)
+		require.NoError(t, err, "for %s", tc.name)
+
+		var results []string
+		for _, d := range decisions {
+			require.NotEmpty(t, d.Policy, "policy name for %s", tc.name)
+			results = append(results, d.Result)
+		}
+		assert.Equal(t, tc.results, results, "results for %s", tc.name)
+		assert.Equal(t, tc.combined, policy.Combine(decisions), "combined result for %s", tc.name)
+	}
+}
+
+func TestEvaluateInvalid(t *testing.T) {
+	input := &policy.Input{App: "myapp", Environment: "staging", Time: time.Now()}
+
+	for _, p := range []v1alpha1.PromotePolicy{
+		{Name: "syntax", Expression: `app ==`},
+		{Name: "unknown-result", Expression: `"maybe"`},
+		{Name: "wrong-type", Expression: `1`},
+		{Name: "action", Expression: `true`, Action: "cheese"},
+	} {
+		_, err := policy.Evaluate([]v1alpha1.PromotePolicy{p}, input)
+		require.Error(t, err, "should fail for policy %s", p.Name)
+	}
+}
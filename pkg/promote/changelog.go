@@ -0,0 +1,119 @@
+package promote
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/blang/semver"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/changelog"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/gitconfig"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// discoverAppGitURL lazily discovers the git URL of the application from the current git repository
+func (o *Options) discoverAppGitURL() error {
+	if o.AppGitURL != "" {
+		return nil
+	}
+	_, gitConf, err := gitclient.FindGitConfigDir("")
+	if err != nil {
+		return fmt.Errorf("failed to find git config dir: %w", err)
+	}
+	o.AppGitURL, err = gitconfig.DiscoverUpstreamGitURL(gitConf, true)
+	if err != nil {
+		return fmt.Errorf("failed to discover application git URL: %w", err)
+	}
+	if o.AppGitURL == "" {
+		return fmt.Errorf("could not to discover application git URL")
+	}
+	return nil
+}
+
+// GenerateChangelogSince generates the changelog of the application from the oldest of the given previously promoted
+// versions to the version being promoted. Returns an empty string if there is no previous version
+func (o *Options) GenerateChangelogSince(previousVersions []string) (string, error) {
+	previousVersion := oldestVersion(previousVersions)
+	if previousVersion == "" {
+		log.Logger().Infof("no previous version of %s found in the environment so not generating a changelog", termcolor.ColorInfo(o.Application))
+		return "", nil
+	}
+	if previousVersion == o.Version {
+		return "", nil
+	}
+	err := o.discoverAppGitURL()
+	if err != nil {
+		return "", err
+	}
+
+	gitter := o.Git()
+	dir := o.Dir
+	if dir == "" {
+		dir = "."
+	}
+	fromTag := changelog.ResolveTag(gitter, dir, previousVersion)
+	toTag := changelog.ResolveTag(gitter, dir, o.Version)
+	if fromTag == "" || toTag == "" {
+		cloneURL := o.AppGitURL
+		if o.ScmClientFactory.GitToken != "" && o.ScmClientFactory.GitUsername != "" {
+			cloneURL, err = o.ScmClientFactory.CreateAuthenticatedURL(cloneURL)
+			if err != nil {
+				return "", fmt.Errorf("failed to create authenticated git URL to clone with for private repositories: %w", err)
+			}
+		}
+		dir, err = gitclient.CloneToDir(gitter, cloneURL, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to clone application git URL %s: %w", o.AppGitURL, err)
+		}
+		defer os.RemoveAll(dir) //nolint:errcheck
+
+		fromTag = changelog.ResolveTag(gitter, dir, previousVersion)
+		toTag = changelog.ResolveTag(gitter, dir, o.Version)
+	}
+	if fromTag == "" {
+		return "", fmt.Errorf("could not find a git tag for the previous version %s in %s", previousVersion, o.AppGitURL)
+	}
+	if toTag == "" {
+		return "", fmt.Errorf("could not find a git tag for the version %s in %s", o.Version, o.AppGitURL)
+	}
+
+	commits, err := changelog.LoadCommits(gitter, dir, fromTag, toTag)
+	if err != nil {
+		return "", err
+	}
+	log.Logger().Infof("generated changelog of %d commits for %s from version %s to %s", len(commits), termcolor.ColorInfo(o.Application), termcolor.ColorInfo(previousVersion), termcolor.ColorInfo(o.Version))
+
+	repoURL := ""
+	gitInfo, err := giturl.ParseGitURL(o.AppGitURL)
+	if err != nil {
+		log.Logger().Warnf("failed to parse application git URL %s so not linking commits and issues: %s", o.AppGitURL, err)
+	} else {
+		repoURL = gitInfo.HttpsURL()
+	}
+	return changelog.Generate(commits, repoURL), nil
+}
+
+// oldestVersion returns the oldest of the given versions so that a changelog covers all of the changes
+func oldestVersion(versions []string) string {
+	answer := ""
+	var answerSemVer *semver.Version
+	for _, v := range versions {
+		if v == "" {
+			continue
+		}
+		sv, err := semver.ParseTolerant(v)
+		if err != nil {
+			if answer == "" {
+				answer = v
+			}
+			continue
+		}
+		if answerSemVer == nil || sv.LT(*answerSemVer) {
+			answer = v
+			answerSemVer = &sv
+		}
+	}
+	return answer
+}
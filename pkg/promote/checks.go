@@ -0,0 +1,147 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
+	"github.com/jenkins-x/go-scm/scm"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+)
+
+// RequiredChecksLabel the label of the status returned when checking the required checks of a Pull Request
+const RequiredChecksLabel = "required-checks"
+
+// PullRequestRequiredChecksStatus returns the combined status of the required checks on the last commit of the Pull
+// Request. Missing checks are treated as pending. The description of the status lists the pending and failing checks
+func (o *Options) PullRequestRequiredChecksStatus(pr *scm.PullRequest, requiredChecks []string) (*scm.Status, error) {
+	scmClient := o.ScmClient
+	if scmClient == nil {
+		return nil, fmt.Errorf("no ScmClient")
+	}
+	ctx := context.Background()
+	fullName := pr.Repository().FullName
+	prLastCommitSha := o.pullRequestLastCommitSha(pr)
+
+	statuses, _, err := scmClient.Repositories.ListStatus(ctx, fullName, prLastCommitSha, &scm.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query repository %s for PR last commit status of %s: %w", fullName, prLastCommitSha, err)
+	}
+	return RequiredChecksStatus(statuses, requiredChecks), nil
+}
+
+// RequiredChecksStatus returns the combined status of the required checks from the given commit statuses which
+// are assumed to be ordered with the most recent status first
+func RequiredChecksStatus(statuses []*scm.Status, requiredChecks []string) *scm.Status {
+	latest := map[string]*scm.Status{}
+	for _, s := range statuses {
+		if s == nil {
+			continue
+		}
+		if latest[s.Label] == nil {
+			latest[s.Label] = s
+		}
+	}
+
+	var pending, failing []string
+	for _, name := range requiredChecks {
+		s := latest[name]
+		switch {
+		case s == nil:
+			pending = append(pending, name+" (missing)")
+		case StateIsPending(s):
+			pending = append(pending, name)
+		case StateIsErrorOrFailure(s):
+			failing = append(failing, name)
+		case s.State != scm.StateSuccess:
+			pending = append(pending, name)
+		}
+	}
+
+	answer := &scm.Status{
+		Label: RequiredChecksLabel,
+		State: scm.StateSuccess,
+		Desc:  "all required checks succeeded: " + strings.Join(requiredChecks, ", "),
+	}
+	var descriptions []string
+	if len(failing) > 0 {
+		answer.State = scm.StateFailure
+		descriptions = append(descriptions, "failing: "+strings.Join(failing, ", "))
+	}
+	if len(pending) > 0 {
+		if answer.State == scm.StateSuccess {
+			answer.State = scm.StatePending
+		}
+		descriptions = append(descriptions, "pending: "+strings.Join(pending, ", "))
+	}
+	if len(descriptions) > 0 {
+		answer.Desc = "required checks " + strings.Join(descriptions, " ")
+	}
+	return answer
+}
+
+// addRequiredChecks adds the given required checks if they are not already present
+func (o *Options) addRequiredChecks(checks []string) {
+	for _, c := range checks {
+		if c != "" && stringhelpers.StringArrayIndex(o.requiredChecks, c) < 0 {
+			o.requiredChecks = append(o.requiredChecks, c)
+		}
+	}
+}
+
+// resetChecks resets the required checks to those specified on the command line and clears the smoke checks before
+// adding those of the environments being promoted
+func (o *Options) resetChecks() {
+	o.requiredChecks = nil
+	o.addRequiredChecks(o.RequiredChecks)
+	o.smokeChecks = map[string][]v1alpha1.SmokeCheck{}
+}
+
+// addEnvironmentChecks adds the required checks and smoke checks configured for the environment
+func (o *Options) addEnvironmentChecks(env *jxcore.EnvironmentConfig, envConfig *v1alpha1.PromoteEnvironment) {
+	if envConfig == nil {
+		return
+	}
+	o.addRequiredChecks(envConfig.RequiredChecks)
+	if len(envConfig.SmokeChecks) > 0 {
+		o.smokeChecks[env.Key] = envConfig.SmokeChecks
+	}
+}
+
+// loadEnvironmentChecks loads the required checks and smoke checks of the environments from the PromoteConfig in
+// their git repository. Used when resuming a promotion as the checks are otherwise loaded when creating the Pull Request
+func (o *Options) loadEnvironmentChecks(envs []*jxcore.EnvironmentConfig) error {
+	o.resetChecks()
+	dirs := map[string]string{}
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir) //nolint:errcheck
+		}
+	}()
+
+	for _, env := range envs {
+		gitURL, err := o.environmentGitURL(env)
+		if err != nil {
+			return err
+		}
+		dir := dirs[gitURL]
+		if dir == "" {
+			dir, err = o.cloneEnvironment(gitURL)
+			if err != nil {
+				return err
+			}
+			dirs[gitURL] = dir
+		}
+		// only the environment settings are needed so there is no need to detect the promote rule
+		promoteConfig, _, err := promoteconfig.LoadPromote(dir, false)
+		if err != nil {
+			return fmt.Errorf("failed to load the PromoteConfig in dir %s: %w", dir, err)
+		}
+		o.addEnvironmentChecks(env, promoteconfig.FindEnvironment(promoteConfig, env.Key))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package promote
+
+import (
+	"context"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/cloudevents"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// EmitCloudEvent sends a CloudEvent of the promotion to the --cloudevents-sink if specified. Failures are logged
+// rather than failing the promotion
+func (o *Options) EmitCloudEvent(eventType string, env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, reason string) {
+	if o.CloudEventsSink == "" {
+		return
+	}
+	source := "jx-promote"
+	if o.Namespace != "" {
+		source = "/jx-promote/" + o.Namespace
+	}
+	emitter := &cloudevents.Emitter{
+		Sink:       o.CloudEventsSink,
+		Source:     source,
+		HTTPClient: o.HTTPClient,
+	}
+	data := &cloudevents.Data{
+		App:              o.promotedApp(),
+		Version:          releaseInfo.Version,
+		PipelineActivity: releaseInfo.PipelineActivity,
+		MergeSHA:         releaseInfo.MergeSHA,
+		Reason:           reason,
+	}
+	if env != nil {
+		data.Environment = env.Key
+	}
+	if pr := releaseInfo.PullRequestInfo; pr != nil {
+		data.PullRequestURL = pr.Link
+	}
+	err := emitter.Emit(context.Background(), eventType, data)
+	if err != nil {
+		log.Logger().Warnf("failed to emit CloudEvent: %s", err.Error())
+	}
+}
@@ -0,0 +1,361 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/client/informers/externalversions"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/envctx"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const optionResync = "resync"
+
+var (
+	controllerLong = templates.LongDesc(`
+		Runs a controller which promotes the apps of the Promotion resources in the development namespace.
+
+		Each Promotion is promoted asynchronously using the same logic as 'jx promote' with the progress written to the
+		status of the Promotion. Pipelines can then create a Promotion and exit rather than waiting for the promotion
+		to complete. If the controller restarts it resumes waiting for the Pull Requests of running promotions.
+
+		The Promotion and Promote CustomResourceDefinitions in pkg/apis/promote/v1alpha1/crds need to be installed.
+`)
+
+	controllerExample = templates.Examples(`
+		# Runs the promotion controller in the current namespace
+		jx promote controller
+	`)
+)
+
+// ControllerOptions the options for the promotion controller
+type ControllerOptions struct {
+	Options
+
+	// Resync the period to reconcile all the Promotion resources
+	Resync string
+
+	// PromoteFunc promotes using the options of a Promotion. Defaults to running the promotion like 'jx promote' using
+	// the telemetry set up once by the controller
+	PromoteFunc func(po *Options) error
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// NewCmdController creates the new command for: jx promote controller
+func NewCmdController() (*cobra.Command, *ControllerOptions) {
+	o := &ControllerOptions{}
+	cmd := &cobra.Command{
+		Use:     "controller",
+		Short:   "Runs a controller which promotes the apps of the Promotion resources in the development namespace",
+		Long:    controllerLong,
+		Example: controllerExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "The Namespace of the development environment to watch for Promotion resources")
+	cmd.Flags().StringVarP(&o.Resync, optionResync, "", "10m", "The period to reconcile all the Promotion resources")
+	cmd.Flags().StringVarP(&o.Timeout, optionTimeout, "t", "1h", "The default timeout to wait for a promotion to succeed if not specified on the Promotion")
+	cmd.Flags().StringVarP(&o.PullRequestPollTime, optionPullRequestPollTime, "", "20s", "Poll time when waiting for a Pull Request to merge")
+	cmd.Flags().StringVarP(&o.DevEnvContext.GitUsername, "git-user", "", "", "Git username used to clone the development environment. If not specified its loaded from the git credentials file")
+	cmd.Flags().StringVarP(&o.DevEnvContext.GitToken, "git-token", "", "", "Git token used to clone the development environment. If not specified its loaded from the git credentials file")
+	cmd.Flags().BoolVarP(&o.NoWaitAfterMerge, "no-wait", "", false, "Disables waiting for completing promotion after the Pull request is merged")
+	cmd.Flags().BoolVarP(&o.Lock, optionLock, "", false, "Acquires a lock of the environment git repository using a Lease before creating each Pull Request which is held until it merges so that concurrent promotions do not race to merge. The promotion is aborted if the lock is lost")
+	cmd.Flags().StringVarP(&o.CloudEventsSink, "cloudevents-sink", "", os.Getenv("K_SINK"), "The URL to send CloudEvents to for each promotion state transition. Defaults to the $K_SINK environment variable")
+	cmd.Flags().StringVarP(&o.StatusResource, optionStatusResource, "", "", "The name of the Promote resource in the namespace to record the last promotion of each app to each environment in its status. The resource is created if it does not exist")
+	return cmd, o
+}
+
+// Run implements this command
+func (o *ControllerOptions) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+	err = o.lazyCreatePromoteClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// the promotions share the global telemetry providers so lets only set them up once
+	shutdown := setupTelemetry()
+	defer shutdown()
+	return o.RunController(ctx)
+}
+
+// RunController reconciles the Promotion resources in the namespace until the context is done
+func (o *ControllerOptions) RunController(ctx context.Context) error {
+	resync, err := time.ParseDuration(o.Resync)
+	if err != nil {
+		return fmt.Errorf("invalid duration format %s for option --%s: %w", o.Resync, optionResync, err)
+	}
+	factory := externalversions.NewSharedInformerFactoryWithOptions(o.PromoteClient, resync, externalversions.WithNamespace(o.Namespace))
+	informer := factory.Promote().V1alpha1().Promotions().Informer()
+
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]())
+	enqueue := func(obj interface{}) {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			log.Logger().Warnf("failed to get the key of %v: %s", obj, err.Error())
+			return
+		}
+		queue.Add(key)
+	}
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, obj interface{}) { enqueue(obj) },
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch Promotions: %w", err)
+	}
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("failed to list the Promotions in namespace %s", o.Namespace)
+	}
+	go func() {
+		<-ctx.Done()
+		queue.ShutDown()
+	}()
+	log.Logger().Infof("watching for Promotions in namespace %s", termcolor.ColorInfo(o.Namespace))
+
+	for {
+		key, shutdown := queue.Get()
+		if shutdown {
+			return nil
+		}
+		err := o.Reconcile(ctx, key)
+		if err != nil {
+			log.Logger().Warnf("failed to reconcile Promotion %s: %s", key, err.Error())
+			queue.AddRateLimited(key)
+		} else {
+			queue.Forget(key)
+		}
+		queue.Done(key)
+	}
+}
+
+// Reconcile starts promoting the Promotion with the given namespace/name key unless it has already completed or is in
+// progress
+func (o *ControllerOptions) Reconcile(ctx context.Context, key string) error {
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	p, err := o.PromoteClient.PromoteV1alpha1().Promotions(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get Promotion %s: %w", key, err)
+	}
+	if promotionCompleted(p) || !o.start(key) {
+		return nil
+	}
+
+	generation := p.Generation
+	p, err = o.updatePromotionStatus(ctx, p, func(status *v1alpha1.PromotionStatus) {
+		if status.Phase != v1alpha1.PromotionPhaseRunning || status.ObservedGeneration != generation {
+			now := metav1.Now()
+			status.StartTime = &now
+			status.CompletionTime = nil
+			status.Environments = nil
+		}
+		status.Phase = v1alpha1.PromotionPhaseRunning
+		status.ObservedGeneration = generation
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               v1alpha1.PromotionConditionSucceeded,
+			Status:             metav1.ConditionUnknown,
+			ObservedGeneration: generation,
+			Reason:             "Promoting",
+			Message:            fmt.Sprintf("promoting %s version %s", p.Spec.App, p.Spec.Version),
+		})
+	})
+	if err != nil {
+		o.finish(key)
+		return err
+	}
+	go func() {
+		defer o.finish(key)
+		o.promote(ctx, p)
+	}()
+	return nil
+}
+
+// promote promotes the Promotion and writes the result to its status
+func (o *ControllerOptions) promote(ctx context.Context, p *v1alpha1.Promotion) {
+	log.Logger().Infof("promoting %s version %s for Promotion %s", termcolor.ColorInfo(p.Spec.App), termcolor.ColorInfo(p.Spec.Version), termcolor.ColorInfo(p.Name))
+	po, err := o.promotionOptions(p)
+	if err == nil {
+		promoteFunc := o.PromoteFunc
+		if promoteFunc == nil {
+			promoteFunc = func(po *Options) error {
+				return po.traceSpan("promote", po.run)
+			}
+		}
+		err = promoteFunc(po)
+	}
+
+	generation := p.Generation
+	_, err2 := o.updatePromotionStatus(ctx, p, func(status *v1alpha1.PromotionStatus) {
+		now := metav1.Now()
+		status.CompletionTime = &now
+		status.Environments = nil
+		if po != nil {
+			for i := range po.Results.Environments {
+				r := &po.Results.Environments[i]
+				status.Environments = append(status.Environments, v1alpha1.PromotionEnvironmentStatus{
+					Name:             r.Environment,
+					State:            string(r.State),
+					PullRequestURL:   r.PullRequestURL,
+					MergeSHA:         r.MergeSHA,
+					PipelineActivity: r.PipelineActivity,
+				})
+			}
+		}
+		condition := metav1.Condition{
+			Type:               v1alpha1.PromotionConditionSucceeded,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             "Promoted",
+			Message:            fmt.Sprintf("promoted %s version %s", p.Spec.App, p.Spec.Version),
+		}
+		status.Phase = v1alpha1.PromotionPhaseSucceeded
+		if err != nil {
+			status.Phase = v1alpha1.PromotionPhaseFailed
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "Failed"
+			condition.Message = err.Error()
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+	})
+	if err2 != nil {
+		log.Logger().Warnf("failed to update the status of Promotion %s: %s", p.Name, err2.Error())
+	}
+	if err != nil {
+		log.Logger().Warnf("failed to promote %s version %s for Promotion %s: %s", p.Spec.App, p.Spec.Version, p.Name, err.Error())
+		return
+	}
+	log.Logger().Infof("promoted %s version %s for Promotion %s", termcolor.ColorInfo(p.Spec.App), termcolor.ColorInfo(p.Spec.Version), termcolor.ColorInfo(p.Name))
+}
+
+// promotionOptions returns the options to promote the Promotion. The PipelineActivity defaults to one derived from the
+// Promotion so that a restarted controller resumes waiting for the Pull Requests of the promotion
+func (o *ControllerOptions) promotionOptions(p *v1alpha1.Promotion) (*Options, error) {
+	spec := &p.Spec
+	if spec.App == "" {
+		return nil, fmt.Errorf("missing spec.app")
+	}
+	if spec.Version == "" {
+		return nil, fmt.Errorf("missing spec.version")
+	}
+	po := o.groupOptions()
+	po.DevEnvContext = envctx.EnvironmentContext{
+		GitUsername: o.DevEnvContext.GitUsername,
+		GitToken:    o.DevEnvContext.GitToken,
+	}
+	po.Namespace = p.Namespace
+	po.Application = spec.App
+	po.Version = spec.Version
+	po.ReleaseName = spec.ReleaseName
+	po.HelmRepositoryURL = spec.HelmRepositoryURL
+	po.AppGitURL = spec.AppGitURL
+	po.Environments = spec.Environments
+	po.BatchMode = true
+	po.IgnoreLocalFiles = true
+	if spec.Timeout != "" {
+		po.Timeout = spec.Timeout
+	}
+	if len(spec.Environments) == 0 {
+		switch spec.Strategy {
+		case "", v1alpha1.PromotionStrategyAuto:
+			po.AllAutomatic = true
+		case v1alpha1.PromotionStrategyAll:
+			po.All = true
+		default:
+			return nil, fmt.Errorf("unsupported spec.strategy %s. Supported values: %s, %s", spec.Strategy, v1alpha1.PromotionStrategyAuto, v1alpha1.PromotionStrategyAll)
+		}
+	}
+	po.Pipeline = spec.Pipeline
+	if po.Pipeline == "" {
+		po.Pipeline = p.Namespace + "/" + p.Name + "/promotion"
+	}
+	po.Build = spec.Build
+	if po.Build == "" {
+		po.Build = strconv.FormatInt(max(p.Generation, 1), 10)
+	}
+	return po, nil
+}
+
+// updatePromotionStatus updates the status of the latest version of the Promotion retrying on conflicts
+func (o *ControllerOptions) updatePromotionStatus(ctx context.Context, p *v1alpha1.Promotion, fn func(status *v1alpha1.PromotionStatus)) (*v1alpha1.Promotion, error) {
+	promotions := o.PromoteClient.PromoteV1alpha1().Promotions(p.Namespace)
+	answer := p
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := promotions.Get(ctx, p.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		fn(&latest.Status)
+		answer, err = promotions.UpdateStatus(ctx, latest, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return p, fmt.Errorf("failed to update the status of Promotion %s in namespace %s: %w", p.Name, p.Namespace, err)
+	}
+	return answer, nil
+}
+
+// start returns true if the Promotion is not already being promoted marking it as running
+func (o *ControllerOptions) start(key string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.running[key] {
+		return false
+	}
+	if o.running == nil {
+		o.running = map[string]bool{}
+	}
+	o.running[key] = true
+	return true
+}
+
+// finish marks the Promotion as no longer being promoted
+func (o *ControllerOptions) finish(key string) {
+	o.mu.Lock()
+	delete(o.running, key)
+	o.mu.Unlock()
+}
+
+// promotionCompleted returns true if the current generation of the Promotion has succeeded or failed
+func promotionCompleted(p *v1alpha1.Promotion) bool {
+	switch p.Status.Phase {
+	case v1alpha1.PromotionPhaseSucceeded, v1alpha1.PromotionPhaseFailed:
+		return p.Status.ObservedGeneration == p.Generation
+	default:
+		return false
+	}
+}
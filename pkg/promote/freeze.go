@@ -0,0 +1,47 @@
+package promote
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/freeze"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const optionOverrideFreeze = "override-freeze"
+
+// CheckFreeze checks if promotions to the environments are frozen at the given time by the promotion windows and
+// freeze periods in the development environment configuration. Returns true if a draft Pull Request should be created
+// or an error if the promotion is not allowed
+func (o *Options) CheckFreeze(envs []*jxcore.EnvironmentConfig, now time.Time) (bool, error) {
+	o.freezeNote = ""
+	draft := false
+	var notes []string
+	for _, env := range envs {
+		envConfig := promoteconfig.FindEnvironment(o.DevPromoteConfig, env.Key)
+		result, err := freeze.Check(envConfig, now)
+		if err != nil {
+			return false, fmt.Errorf("failed to check the promotion window of environment %s: %w", env.Key, err)
+		}
+		if !result.Frozen {
+			continue
+		}
+		switch {
+		case o.OverrideFreeze != "":
+			log.Logger().Warnf("overriding %s for environment %s: %s", result.Reason, env.Key, o.OverrideFreeze)
+			notes = append(notes, fmt.Sprintf("**%s** of environment %s was overridden: %s", result.Reason, env.Key, o.OverrideFreeze))
+		case result.Action == freeze.ActionDraft:
+			log.Logger().Infof("creating a draft Pull Request as environment %s is %s", termcolor.ColorInfo(env.Key), result.Reason)
+			notes = append(notes, fmt.Sprintf("this Pull Request is on hold as environment %s is %s", env.Key, result.Reason))
+			draft = true
+		default:
+			return false, fmt.Errorf("cannot promote to environment %s as it is %s. Use --%s with a reason to override", env.Key, result.Reason, optionOverrideFreeze)
+		}
+	}
+	o.freezeNote = strings.Join(notes, "\n\n")
+	return draft, nil
+}
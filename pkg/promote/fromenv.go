@@ -0,0 +1,68 @@
+package promote
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const optionFromEnvironment = "from-env"
+
+// validateFromEnvironment validates the --from-env option
+func (o *Options) validateFromEnvironment() error {
+	if o.FromEnvironment == "" {
+		return nil
+	}
+	if o.Version != "" {
+		return fmt.Errorf("cannot specify both --%s and --version", optionFromEnvironment)
+	}
+	if o.FromManifest != "" {
+		return fmt.Errorf("cannot specify both --%s and --from-manifest", optionFromEnvironment)
+	}
+	if Contains(o.Environments, o.FromEnvironment) {
+		return fmt.Errorf("cannot promote from environment %s to itself", o.FromEnvironment)
+	}
+	return nil
+}
+
+// ResolveFromEnvironment defaults the version, chart repository and release name of the app to those currently
+// deployed in the --from-env environment
+func (o *Options) ResolveFromEnvironment() error {
+	env := o.findEnvironment(o.FromEnvironment)
+	if env == nil {
+		return fmt.Errorf("could not find environment %s", o.FromEnvironment)
+	}
+	gitURL, err := o.environmentGitURL(env)
+	if err != nil {
+		return fmt.Errorf("failed to find the git URL of environment %s: %w", env.Key, err)
+	}
+	dir, err := o.cloneEnvironment(gitURL)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	app := ManifestApp{
+		App:         o.Application,
+		ReleaseName: o.ReleaseName,
+		Alias:       o.Alias,
+	}
+	release, err := o.environmentRelease(dir, env, app)
+	if err != nil {
+		return err
+	}
+	if release == nil || release.Version == "" {
+		return fmt.Errorf("app %s is not deployed in environment %s", o.Application, env.Key)
+	}
+	o.Version = release.Version
+	if o.HelmRepositoryURL == "" {
+		o.HelmRepositoryURL = release.HelmRepositoryURL
+	}
+	if o.ReleaseName == "" {
+		o.ReleaseName = release.ReleaseName
+	}
+	log.Logger().Infof("promoting %s version %s from environment %s", termcolor.ColorInfo(o.Application), termcolor.ColorInfo(o.Version), termcolor.ColorInfo(env.Key))
+	return nil
+}
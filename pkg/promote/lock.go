@@ -0,0 +1,103 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/lease"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	optionLock    = "lock"
+	optionLockTTL = "lock-ttl"
+)
+
+// acquireLock waits to acquire the promotion lock of the git repository of the group of environments if --lock is
+// enabled so that concurrent promotions into the same repository do not race to merge. The wait is limited by --timeout
+func (o *Options) acquireLock(group []*jxcore.EnvironmentConfig) error {
+	if !o.Lock || o.EnvDir != "" {
+		return nil
+	}
+	gitURL, err := o.environmentGitURL(group[0])
+	if err != nil {
+		return err
+	}
+	ttl := lease.DefaultTTL
+	if o.LockTTL != "" {
+		ttl, err = time.ParseDuration(o.LockTTL)
+		if err != nil {
+			return fmt.Errorf("invalid duration format %s for option --%s: %w", o.LockTTL, optionLockTTL, err)
+		}
+	}
+	l := &lease.Lock{
+		KubeClient: o.KubeClient,
+		Namespace:  o.Namespace,
+		GitURL:     gitURL,
+		Holder:     o.lockHolder(group),
+		TTL:        ttl,
+	}
+
+	ctx := context.Background()
+	if o.TimeoutDuration != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *o.TimeoutDuration)
+		defer cancel()
+	}
+	end := o.StartSpan("acquire lock", attribute.String("git.url", gitURL))
+	err = l.Acquire(ctx)
+	end(err)
+	if err != nil {
+		return err
+	}
+	o.lock = l
+	return nil
+}
+
+// releaseLock releases the promotion lock if it is held
+func (o *Options) releaseLock() {
+	if o.lock == nil {
+		return
+	}
+	err := o.lock.Release(context.Background())
+	if err != nil {
+		log.Logger().Warnf("failed to release the promotion lock: %s", err.Error())
+	}
+	o.lock = nil
+}
+
+// checkLock returns an error if the promotion lock was lost while it was held so that the promotion is aborted rather
+// than racing the new holder to push or merge
+func (o *Options) checkLock() error {
+	if o.lock == nil {
+		return nil
+	}
+	err := o.lock.Err()
+	if err != nil {
+		return fmt.Errorf("aborting the promotion: %w", err)
+	}
+	return nil
+}
+
+// lockHolder returns the identity of the promotion to the group of environments used as the holder of the lock
+func (o *Options) lockHolder(group []*jxcore.EnvironmentConfig) string {
+	var apps []string
+	for _, app := range o.PromoteApps() {
+		if app.Version != "" {
+			apps = append(apps, app.App+"@"+app.Version)
+		} else {
+			apps = append(apps, app.App)
+		}
+	}
+	holder := strings.Join(apps, ",") + " to " + groupNames(group)
+	host, err := os.Hostname()
+	if err == nil && host != "" {
+		holder = host + "/" + holder
+	}
+	return holder
+}
@@ -0,0 +1,125 @@
+package promote
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"sigs.k8s.io/yaml"
+)
+
+// Manifest a list of applications to promote together in a single Pull Request
+type Manifest struct {
+	// Apps the applications to promote
+	Apps []ManifestApp `json:"apps"`
+}
+
+// ManifestApp an application version to promote
+type ManifestApp struct {
+	// App the name of the application chart
+	App string `json:"app"`
+
+	// Version the version of the application to promote
+	Version string `json:"version"`
+
+	// ReleaseName the optional name of the helm release. Defaults to the app name
+	ReleaseName string `json:"releaseName,omitempty"`
+
+	// Alias the optional alias used in the 'requirements.yaml' file
+	Alias string `json:"alias,omitempty"`
+
+	// HelmRepoURL the optional helm repository URL of the app. Defaults to the --helm-repo-url option
+	HelmRepoURL string `json:"helmRepoURL,omitempty"`
+
+	// Changelog the optional file containing the changelog of the app to add to the pull request body
+	Changelog string `json:"changelog,omitempty"`
+}
+
+// LoadManifest loads the applications to promote from the given manifest file
+func LoadManifest(fileName string) (*Manifest, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest file %s: %w", fileName, err)
+	}
+	manifest := &Manifest{}
+	err = yaml.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML manifest file %s: %w", fileName, err)
+	}
+	if len(manifest.Apps) == 0 {
+		return nil, fmt.Errorf("no apps specified in manifest file %s", fileName)
+	}
+	for i := range manifest.Apps {
+		app := &manifest.Apps[i]
+		if app.App == "" {
+			return nil, fmt.Errorf("missing app name for entry %d in manifest file %s", i, fileName)
+		}
+		if app.Version == "" {
+			return nil, fmt.Errorf("missing version for app %s in manifest file %s", app.App, fileName)
+		}
+		if app.ReleaseName == "" {
+			app.ReleaseName = app.App
+		}
+	}
+	return manifest, nil
+}
+
+// PromoteApps returns the applications to promote which are either those in the manifest or the current application
+func (o *Options) PromoteApps() []ManifestApp {
+	if len(o.ManifestApps) > 0 {
+		answer := make([]ManifestApp, 0, len(o.ManifestApps))
+		for _, app := range o.ManifestApps {
+			if app.HelmRepoURL == "" {
+				app.HelmRepoURL = o.HelmRepositoryURL
+			}
+			answer = append(answer, app)
+		}
+		return answer
+	}
+	if o.Application == "" {
+		return nil
+	}
+	return []ManifestApp{
+		{
+			App:         o.Application,
+			Version:     o.Version,
+			ReleaseName: o.ReleaseName,
+			Alias:       o.Alias,
+			HelmRepoURL: o.HelmRepositoryURL,
+			Changelog:   o.AddChangelog,
+		},
+	}
+}
+
+// promotedApp returns the name of the application being promoted or the names of the apps in the manifest
+func (o *Options) promotedApp() string {
+	return joinApps(o.PromoteApps(), func(app *ManifestApp) string { return app.App })
+}
+
+// joinApps joins the given value of each app to promote so that a manifest can be described by a single string
+func joinApps(apps []ManifestApp, fn func(app *ManifestApp) string) string {
+	var values []string
+	for i := range apps {
+		values = append(values, fn(&apps[i]))
+	}
+	return strings.Join(values, ", ")
+}
+
+// manifestChangelog returns the changelogs of each app in the manifest prefixed with the app name
+func manifestChangelog(apps []ManifestApp) (string, error) {
+	buf := strings.Builder{}
+	for _, app := range apps {
+		if app.Changelog == "" {
+			continue
+		}
+		data, err := os.ReadFile(app.Changelog)
+		if err != nil {
+			return "", fmt.Errorf("failed to read changelog file %s for app %s: %w", app.Changelog, app.App, err)
+		}
+		buf.WriteString(environments.ChangelogPrefix(app.App))
+		buf.WriteString("\n")
+		buf.WriteString(string(data))
+	}
+	return strings.TrimPrefix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,262 @@
+package promote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/jenkins-x/go-scm/scm"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	optionMergeMethod       = "merge-method"
+	optionNativeAutoMerge   = "native-auto-merge"
+	optionVerifyRollout     = "verify-rollout"
+	optionRollbackOnFailure = "rollback-on-failure"
+
+	// MergeMethodMerge merges the Pull Request with a merge commit
+	MergeMethodMerge = "merge"
+
+	// MergeMethodSquash squashes the commits of the Pull Request into a single commit
+	MergeMethodSquash = "squash"
+
+	// MergeMethodRebase rebases the commits of the Pull Request onto the base branch
+	MergeMethodRebase = "rebase"
+
+	// DefaultMergeCommitTemplate the default template of the commit title when merging a promotion Pull Request
+	DefaultMergeCommitTemplate = "jx promote automatically merged promotion PR"
+
+	labelHold = "do-not-merge/hold"
+)
+
+// MergeMethods the supported merge methods
+var MergeMethods = []string{MergeMethodMerge, MergeMethodSquash, MergeMethodRebase}
+
+// MergeCommitContext the context used to evaluate the merge commit template
+type MergeCommitContext struct {
+	// App the name of the app being promoted
+	App string
+
+	// Version the version being promoted
+	Version string
+
+	// Environment the name of the environment being promoted to
+	Environment string
+
+	// PullRequestNumber the number of the promotion Pull Request
+	PullRequestNumber int
+
+	// PullRequestTitle the title of the promotion Pull Request
+	PullRequestTitle string
+}
+
+// validateMerge validates the merge method and merge commit template options
+func (o *Options) validateMerge() error {
+	if o.MergeMethod != "" && !Contains(MergeMethods, o.MergeMethod) {
+		return options.InvalidOption(optionMergeMethod, o.MergeMethod, MergeMethods)
+	}
+	if o.MergeCommitTemplate == "" {
+		o.MergeCommitTemplate = DefaultMergeCommitTemplate
+	}
+	_, err := template.New("merge").Parse(o.MergeCommitTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse merge commit template %s: %w", o.MergeCommitTemplate, err)
+	}
+	// the promotion is not waited for once the git provider merges the Pull Request so nothing can be verified
+	if o.NativeAutoMerge && o.VerifyRollout {
+		return fmt.Errorf("cannot specify both --%s and --%s", optionNativeAutoMerge, optionVerifyRollout)
+	}
+	if o.NativeAutoMerge && o.RollbackOnFailure {
+		return fmt.Errorf("cannot specify both --%s and --%s", optionNativeAutoMerge, optionRollbackOnFailure)
+	}
+	return nil
+}
+
+// MergeCommitTitle returns the commit title to use when merging the promotion Pull Request to the environment
+func (o *Options) MergeCommitTitle(env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, pr *scm.PullRequest) (string, error) {
+	templateText := o.MergeCommitTemplate
+	if templateText == "" {
+		templateText = DefaultMergeCommitTemplate
+	}
+	tmpl, err := template.New("merge").Parse(templateText)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse merge commit template %s: %w", templateText, err)
+	}
+	ctx := &MergeCommitContext{
+		App:     o.promotedApp(),
+		Version: releaseInfo.Version,
+	}
+	if env != nil {
+		ctx.Environment = env.Key
+	}
+	if pr != nil {
+		ctx.PullRequestNumber = pr.Number
+		ctx.PullRequestTitle = pr.Title
+	}
+	buf := &strings.Builder{}
+	err = tmpl.Execute(buf, ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate merge commit template %s: %w", templateText, err)
+	}
+	return buf.String(), nil
+}
+
+// mergeOptions returns the options to merge the promotion Pull Request with
+func (o *Options) mergeOptions(env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, pr *scm.PullRequest) (*scm.PullRequestMergeOptions, error) {
+	title, err := o.MergeCommitTitle(env, releaseInfo, pr)
+	if err != nil {
+		return nil, err
+	}
+	return &scm.PullRequestMergeOptions{
+		CommitTitle: title,
+		MergeMethod: o.MergeMethod,
+	}, nil
+}
+
+// EnableNativeAutoMerge enables the git providers native auto merge (or merge queue) on the promotion Pull Request
+// so that the git provider merges it once all the required checks pass
+func (o *Options) EnableNativeAutoMerge(env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo) error {
+	pr := releaseInfo.PullRequestInfo
+	if pr == nil {
+		return fmt.Errorf("no Pull Request to enable auto merge on")
+	}
+	if scmhelpers.ContainsLabel(pr.Labels, labelHold) {
+		return fmt.Errorf("pull request %s has the %s label", pr.Link, labelHold)
+	}
+	scmClient := o.ScmClient
+	if scmClient == nil {
+		return fmt.Errorf("no ScmClient")
+	}
+	mergeOptions, err := o.mergeOptions(env, releaseInfo, pr)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	fullName := pr.Repository().FullName
+
+	switch scmClient.Driver {
+	case scm.DriverGithub:
+		err = enableGitHubAutoMerge(ctx, scmClient, fullName, pr.Number, mergeOptions)
+	case scm.DriverGitlab:
+		mergeOptions.MergeWhenPipelineSucceeds = true
+		_, err = scmClient.PullRequests.Merge(ctx, fullName, pr.Number, mergeOptions)
+	default:
+		return fmt.Errorf("native auto merge is not supported for git provider %s", scmClient.Driver.String())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enable auto merge on Pull Request %s: %w", pr.Link, err)
+	}
+	log.Logger().Infof("enabled auto merge on Pull Request %s", termcolor.ColorInfo(pr.Link))
+	return nil
+}
+
+// enableGitHubAutoMerge enables auto merge via the GitHub GraphQL API. If the base branch requires a merge queue
+// the Pull Request is added to the queue once the required checks pass
+func enableGitHubAutoMerge(ctx context.Context, scmClient *scm.Client, fullName string, number int, mergeOptions *scm.PullRequestMergeOptions) error {
+	owner, name := scm.Split(fullName)
+	query := `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      id
+    }
+  }
+}`
+	result := struct {
+		Repository struct {
+			PullRequest struct {
+				ID string `json:"id"`
+			} `json:"pullRequest"`
+		} `json:"repository"`
+	}{}
+	err := gitHubGraphQL(ctx, scmClient, query, map[string]interface{}{
+		"owner":  owner,
+		"name":   name,
+		"number": number,
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("failed to find the id of pull request %d in %s: %w", number, fullName, err)
+	}
+	id := result.Repository.PullRequest.ID
+	if id == "" {
+		return fmt.Errorf("no id found for pull request %d in %s", number, fullName)
+	}
+
+	method := mergeOptions.MergeMethod
+	if method == "" {
+		method = MergeMethodMerge
+	}
+	mutation := `mutation($id: ID!, $method: PullRequestMergeMethod!, $headline: String) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method, commitHeadline: $headline}) {
+    clientMutationId
+  }
+}`
+	return gitHubGraphQL(ctx, scmClient, mutation, map[string]interface{}{
+		"id":       id,
+		"method":   strings.ToUpper(method),
+		"headline": mergeOptions.CommitTitle,
+	}, nil)
+}
+
+// gitHubGraphQL invokes a GraphQL query or mutation on GitHub and unmarshals the data into the result if not nil
+func gitHubGraphQL(ctx context.Context, scmClient *scm.Client, query string, variables map[string]interface{}, result interface{}) error {
+	if scmClient.GraphQLURL == nil {
+		return fmt.Errorf("no GraphQL URL for the git provider")
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal GraphQL request: %w", err)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	res, err := scmClient.Do(ctx, &scm.Request{
+		Method: http.MethodPost,
+		Path:   scmClient.GraphQLURL.String(),
+		Header: header,
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to invoke GraphQL: %w", err)
+	}
+	defer res.Body.Close() //nolint:errcheck
+	if res.Status >= http.StatusMultipleChoices {
+		return fmt.Errorf("GraphQL request failed with status %d", res.Status)
+	}
+
+	response := struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal GraphQL response: %w", err)
+	}
+	if len(response.Errors) > 0 {
+		var messages []string
+		for _, e := range response.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("GraphQL request failed: %s", strings.Join(messages, ", "))
+	}
+	if result == nil || len(response.Data) == 0 {
+		return nil
+	}
+	err = json.Unmarshal(response.Data, result)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal GraphQL data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package promote
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/notify"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+)
+
+// Notify sends the promotion event to the notifiers configured in the development environment configuration
+func (o *Options) Notify(event string, env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, reason string) {
+	if o.DevPromoteConfig == nil || len(o.DevPromoteConfig.Spec.Notifiers) == 0 {
+		return
+	}
+	e := &notify.Event{
+		Event:   event,
+		App:     o.promotedApp(),
+		Version: releaseInfo.Version,
+		Reason:  reason,
+	}
+	var envNames []string
+	for _, ec := range releaseInfo.Environments {
+		envNames = append(envNames, ec.Key)
+	}
+	if len(envNames) == 0 && env != nil {
+		envNames = append(envNames, env.Key)
+	}
+	e.Environment = strings.Join(envNames, ", ")
+	if pr := releaseInfo.PullRequestInfo; pr != nil {
+		e.PullRequestURL = pr.Link
+		e.PullRequestNumber = pr.Number
+	}
+	e.MergeSHA = releaseInfo.MergeSHA
+	notify.NotifyAll(context.Background(), o.DevPromoteConfig.Spec.Notifiers, o.HTTPClient, e)
+}
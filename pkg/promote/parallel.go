@@ -0,0 +1,155 @@
+package promote
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/activities"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"golang.org/x/exp/slices"
+)
+
+const optionParallel = "parallel"
+
+// promoteGroupsInParallel promotes to each group of environments concurrently using a copy of the options per group.
+// A group waits for the earlier groups containing the upstream environments of its environments and is not promoted
+// if any of them fail. The results of all the groups are aggregated
+func (o *Options) promoteGroupsInParallel(groups [][]*jxcore.EnvironmentConfig) error {
+	dependencies := o.groupDependencies(groups)
+	done := make([]chan struct{}, len(groups))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	errs := make([]error, len(groups))
+	copies := make([]*Options, len(groups))
+
+	// the groups update the same PipelineActivity so lets serialise the updates to avoid losing steps
+	o.activityLock = &sync.Mutex{}
+	defer func() {
+		o.activityLock = nil
+	}()
+
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		wg.Add(1)
+		go func(i int, group []*jxcore.EnvironmentConfig) {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, d := range dependencies[i] {
+				log.Logger().Infof("waiting for the promotion to %s before promoting to %s", termcolor.ColorInfo(groupNames(groups[d])), termcolor.ColorInfo(groupNames(group)))
+				<-done[d]
+				if errs[d] != nil {
+					errs[i] = fmt.Errorf("not promoting to %s as the promotion to upstream %s failed", groupNames(group), groupNames(groups[d]))
+					return
+				}
+			}
+			po := o.groupOptions()
+			copies[i] = po
+			err := po.promoteGroup(group)
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to promote to %s: %w", groupNames(group), err)
+			}
+		}(i, group)
+	}
+	wg.Wait()
+
+	for i, po := range copies {
+		if po == nil {
+			o.addSkippedResults(groups[i : i+1])
+			continue
+		}
+		o.Results.Environments = append(o.Results.Environments, po.Results.Environments...)
+		if po.ReleaseInfo != nil {
+			o.ReleaseInfo = po.ReleaseInfo
+		}
+		if o.ScmClient == nil {
+			o.ScmClient = po.ScmClient
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// groupOptions returns a copy of the options to promote a group of environments concurrently with other groups
+// clearing the state which is modified when creating a Pull Request and copying the maps and slices so that no group
+// modifies them for another. The clients, Clock and webhook listener are shared so must be safe for concurrent use
+func (o *Options) groupOptions() *Options {
+	po := *o
+	po.RequiredChecks = slices.Clone(o.RequiredChecks)
+	po.PolicyLabels = maps.Clone(o.PolicyLabels)
+	po.PullRequestHandlers = maps.Clone(o.PullRequestHandlers)
+	po.ManifestApps = slices.Clone(o.ManifestApps)
+	po.SparseCheckoutPatterns = slices.Clone(o.SparseCheckoutPatterns)
+	po.requiredChecks = slices.Clone(o.requiredChecks)
+	po.smokeChecks = maps.Clone(o.smokeChecks)
+	po.BranchName = ""
+	po.OutDir = ""
+	po.PullRequestFilter = nil
+	po.PullRequestNumber = 0
+	po.Labels = nil
+	po.PushedCommitSha = ""
+	po.ReleaseInfo = nil
+	po.Results = PromoteResults{}
+	po.lock = nil
+	return &po
+}
+
+// onPromotePullRequest updates the Pull Request step of the PipelineActivity of the promotion
+func (o *Options) onPromotePullRequest(promoteKey *activities.PromoteStepActivityKey, fn activities.PromotePullRequestFn) error {
+	if o.activityLock != nil {
+		o.activityLock.Lock()
+		defer o.activityLock.Unlock()
+	}
+	return promoteKey.OnPromotePullRequest(o.KubeClient, o.JXClient, o.Namespace, fn)
+}
+
+// onPromoteUpdate updates the update step of the PipelineActivity of the promotion
+func (o *Options) onPromoteUpdate(promoteKey *activities.PromoteStepActivityKey, fn activities.PromoteUpdateFn) error {
+	if o.activityLock != nil {
+		o.activityLock.Lock()
+		defer o.activityLock.Unlock()
+	}
+	return promoteKey.OnPromoteUpdate(o.KubeClient, o.JXClient, o.Namespace, fn)
+}
+
+// groupDependencies returns the indexes of the earlier groups each group depends on as they contain the upstream
+// environments of the environments in the group
+func (o *Options) groupDependencies(groups [][]*jxcore.EnvironmentConfig) [][]int {
+	groupIndex := map[string]int{}
+	for i, group := range groups {
+		for _, env := range group {
+			groupIndex[env.Key] = i
+		}
+	}
+	dependencies := make([][]int, len(groups))
+	for i, group := range groups {
+		for _, env := range group {
+			envConfig := promoteconfig.FindEnvironment(o.DevPromoteConfig, env.Key)
+			if envConfig == nil {
+				continue
+			}
+			for _, upstream := range envConfig.Upstreams {
+				d, ok := groupIndex[upstream]
+				if ok && d < i && !slices.Contains(dependencies[i], d) {
+					dependencies[i] = append(dependencies[i], d)
+				}
+			}
+		}
+	}
+	return dependencies
+}
+
+// groupNames returns the names of the environments in the group
+func groupNames(group []*jxcore.EnvironmentConfig) string {
+	var names []string
+	for _, env := range group {
+		names = append(names, env.Key)
+	}
+	return strings.Join(names, ", ")
+}
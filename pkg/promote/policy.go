@@ -0,0 +1,156 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/policy"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/naming"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EvaluatePolicies evaluates the policies in the development environment configuration for each app being promoted to
+// each environment. Returns true if a draft Pull Request should be created or an error if the promotion is denied
+func (o *Options) EvaluatePolicies(envs []*jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, now time.Time) (bool, error) {
+	o.policyNote = ""
+	if o.DevPromoteConfig == nil || len(o.DevPromoteConfig.Spec.Policies) == 0 {
+		return false, nil
+	}
+	author := o.policyAuthor()
+	inputs := make([]*policy.Input, 0, len(releaseInfo.Apps))
+	for _, app := range releaseInfo.Apps {
+		inputs = append(inputs, &policy.Input{
+			App:     app.App,
+			Version: app.Version,
+			Labels:  o.policyLabels(app),
+			Author:  author,
+			Time:    now,
+		})
+	}
+
+	draft := false
+	var notes []string
+	for _, env := range envs {
+		var envDecisions []policy.Decision
+		for _, input := range inputs {
+			input.Environment = env.Key
+			decisions, err := policy.Evaluate(o.DevPromoteConfig.Spec.Policies, input)
+			if err != nil {
+				return false, fmt.Errorf("failed to evaluate policies for app %s to environment %s: %w", input.App, env.Key, err)
+			}
+			for _, d := range decisions {
+				log.Logger().Infof("policy %s decided %s for app %s version %s to environment %s", termcolor.ColorInfo(d.Policy), termcolor.ColorInfo(d.Result), input.App, input.Version, env.Key)
+			}
+			envDecisions = append(envDecisions, decisions...)
+			switch policy.Combine(decisions) {
+			case policy.ResultDeny:
+				// lets record the decisions explaining why the promotion failed
+				setPolicyDecisions(releaseInfo, env.Key, envDecisions)
+				d := findDecision(decisions, policy.ResultDeny)
+				return false, fmt.Errorf("cannot promote %s to environment %s as it is denied by policy %s%s", input.App, env.Key, d.Policy, policyMessage(d))
+			case policy.ResultRequireManual:
+				for _, d := range decisions {
+					if d.Result == policy.ResultRequireManual {
+						notes = append(notes, fmt.Sprintf("this Pull Request requires manual approval of %s to environment %s by policy %s%s", input.App, env.Key, d.Policy, policyMessage(d)))
+					}
+				}
+				draft = true
+			}
+		}
+		setPolicyDecisions(releaseInfo, env.Key, envDecisions)
+	}
+	o.policyNote = strings.Join(notes, "\n\n")
+	return draft, nil
+}
+
+// findDecision returns the first decision with the given result
+func findDecision(decisions []policy.Decision, result string) policy.Decision {
+	for _, d := range decisions {
+		if d.Result == result {
+			return d
+		}
+	}
+	return policy.Decision{}
+}
+
+// setPolicyDecisions records the decisions of the policies for the environment
+func setPolicyDecisions(releaseInfo *ReleaseInfo, envName string, decisions []policy.Decision) {
+	if releaseInfo.PolicyDecisions == nil {
+		releaseInfo.PolicyDecisions = map[string][]policy.Decision{}
+	}
+	releaseInfo.PolicyDecisions[envName] = decisions
+}
+
+// policyLabels returns the labels of the Release resource of the app. The --policy-label options only add labels which
+// are missing from the Release so that they cannot override the labels recorded in the cluster
+func (o *Options) policyLabels(app ManifestApp) map[string]string {
+	labels := map[string]string{}
+	for k, v := range o.PolicyLabels {
+		labels[k] = v
+	}
+	release := o.findRelease(app)
+	if release == nil {
+		return labels
+	}
+	for k, v := range release.Labels {
+		if value, ok := labels[k]; ok && value != v {
+			log.Logger().Warnf("ignoring --policy-label %s=%s as the Release %s has the label value %s", k, value, release.Name, v)
+		}
+		labels[k] = v
+	}
+	return labels
+}
+
+// findRelease returns the Release resource of the app being promoted or nil if there is none
+func (o *Options) findRelease(app ManifestApp) *v1.Release {
+	if o.releaseResource != nil && len(o.ManifestApps) == 0 {
+		return o.releaseResource
+	}
+	if o.JXClient == nil || app.Version == "" {
+		return nil
+	}
+	name := naming.ToValidNameWithDots(app.App + "-" + app.Version)
+	release, err := o.JXClient.JenkinsV1().Releases(o.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Logger().Warnf("failed to find Release %s in namespace %s: %s", name, o.Namespace, err.Error())
+		}
+		return nil
+	}
+	return release
+}
+
+// policyAuthor returns the author email of the latest commit in the source directory. The --author option is only used
+// if the author cannot be found from git so that it cannot override the author of the change
+func (o *Options) policyAuthor() string {
+	if o.IgnoreLocalFiles {
+		return o.Author
+	}
+	text, err := o.Git().Command(o.Dir, "log", "-1", "--format=%ae")
+	if err != nil {
+		log.Logger().Debugf("failed to find the author of the latest commit in dir %s: %s", o.Dir, err.Error())
+		return o.Author
+	}
+	author := strings.TrimSpace(text)
+	if author == "" {
+		return o.Author
+	}
+	if o.Author != "" && o.Author != author {
+		log.Logger().Warnf("ignoring --author %s as the author of the latest commit is %s", o.Author, author)
+	}
+	return author
+}
+
+func policyMessage(d policy.Decision) string {
+	if d.Message == "" {
+		return ""
+	}
+	return ": " + d.Message
+}
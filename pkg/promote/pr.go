@@ -3,7 +3,11 @@ package promote
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
 	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/requirements"
 
@@ -12,34 +16,38 @@ import (
 	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
 	"github.com/jenkins-x-plugins/jx-promote/pkg/rules"
 	"github.com/jenkins-x-plugins/jx-promote/pkg/rules/factory"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/telemetry"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/gitconfig"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/versionstream"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 )
 
 func (o *Options) PromoteViaPullRequest(envs []*jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, draftPR bool) error {
-	version := o.Version
-	versionName := version
-	if versionName == "" {
-		versionName = "latest"
+	apps := o.PromoteApps()
+	if len(apps) == 0 {
+		return fmt.Errorf("no applications to promote")
 	}
-	app := o.Application
+	multipleApps := len(o.ManifestApps) > 0
 
-	source := "promote-" + app + "-" + versionName
 	var labels []string
 
 	// TODO: Support more labels. I'm thinking owner...
 	for _, env := range envs {
 		envName := env.Key
-		source += "-" + envName
 		labels = append(labels, "env/"+envName)
 	}
 
-	var dependencyLabel = "dependency/" + releaseInfo.FullAppName
+	for _, app := range apps {
+		var dependencyLabel = "dependency/" + o.fullAppName(app.App)
 
-	if len(dependencyLabel) > 49 {
-		dependencyLabel = dependencyLabel[:49]
+		if len(dependencyLabel) > 49 {
+			dependencyLabel = dependencyLabel[:49]
+		}
+		labels = append(labels, dependencyLabel)
 	}
-	labels = append(labels, dependencyLabel)
 
 	if o.ReusePullRequest && o.PullRequestFilter == nil {
 		o.PullRequestFilter = &environments.PullRequestFilter{Labels: labels}
@@ -53,14 +61,44 @@ func (o *Options) PromoteViaPullRequest(envs []*jxcore.EnvironmentConfig, releas
 		labels = append(labels, "do-not-merge/hold")
 	}
 
-	o.CommitTitle = fmt.Sprintf("chore: promote %s to version %s", app, versionName)
-	o.CommitMessage = comment
-	if o.AddChangelog != "" {
-		changelog, err := os.ReadFile(o.AddChangelog)
+	o.CommitChangelog = ""
+	generateChangelog := o.GenerateChangelog && o.AddChangelog == "" && !multipleApps
+	o.ReplaceChangelog = generateChangelog
+	if multipleApps {
+		var names, lines []string
+		for _, app := range apps {
+			names = append(names, app.App)
+			lines = append(lines, fmt.Sprintf("* %s to version %s", app.App, app.Version))
+		}
+		o.CommitTitle = fmt.Sprintf("chore: promote %s", strings.Join(names, ", "))
+		o.CommitMessage = strings.Join(lines, "\n") + "\n\n" + comment
+
+		changelog, err := manifestChangelog(apps)
 		if err != nil {
-			return fmt.Errorf("failed to read changelog file %s: %w", o.AddChangelog, err)
+			return err
+		}
+		o.CommitChangelog = changelog
+	} else {
+		versionName := o.Version
+		if versionName == "" {
+			versionName = "latest"
+		}
+		o.CommitTitle = fmt.Sprintf("chore: promote %s to version %s", o.Application, versionName)
+		o.CommitMessage = comment
+		if o.AddChangelog != "" {
+			changelog, err := os.ReadFile(o.AddChangelog)
+			if err != nil {
+				return fmt.Errorf("failed to read changelog file %s: %w", o.AddChangelog, err)
+			}
+			o.CommitChangelog = string(changelog)
 		}
-		o.CommitChangelog = string(changelog)
+	}
+
+	if o.freezeNote != "" {
+		o.CommitMessage += "\n\n" + o.freezeNote
+	}
+	if o.policyNote != "" {
+		o.CommitMessage += "\n\n" + o.policyNote
 	}
 
 	envDir := ""
@@ -70,7 +108,10 @@ func (o *Options) PromoteViaPullRequest(envs []*jxcore.EnvironmentConfig, releas
 
 	o.Function = func() error {
 		dir := o.OutDir
+		o.resetChecks()
 
+		var previousVersions []string
+		directPush := !draftPR
 		for _, env := range envs {
 			promoteNS := EnvironmentNamespace(env)
 			promoteConfig, _, err := promoteconfig.Discover(dir, promoteNS)
@@ -78,72 +119,207 @@ func (o *Options) PromoteViaPullRequest(envs []*jxcore.EnvironmentConfig, releas
 				return fmt.Errorf("failed to discover the PromoteConfig in dir %s: %w", dir, err)
 			}
 
-			r := &rules.PromoteRule{
-				TemplateContext: rules.TemplateContext{
-					GitURL:            "",
-					Version:           o.Version,
-					AppName:           o.Application,
-					ChartAlias:        o.Alias,
-					Namespace:         o.Namespace,
-					HelmRepositoryURL: o.HelmRepositoryURL,
-					ReleaseName:       o.ReleaseName,
-				},
-				Dir:           dir,
-				Config:        *promoteConfig,
-				DevEnvContext: &o.DevEnvContext,
+			// lets only push directly if all the environments in the group are configured to
+			envConfig := promoteconfig.FindEnvironment(promoteConfig, env.Key)
+			if envConfig == nil || !envConfig.DirectPush {
+				directPush = false
 			}
+			o.addEnvironmentChecks(env, envConfig)
 
-			// lets check if we need the apps git URL
-			if promoteConfig.Spec.FileRule != nil || promoteConfig.Spec.KptRule != nil {
-				if o.AppGitURL == "" {
-					_, gitConf, err := gitclient.FindGitConfigDir("")
-					if err != nil {
-						return fmt.Errorf("failed to find git config dir: %w", err)
-					}
-					o.AppGitURL, err = gitconfig.DiscoverUpstreamGitURL(gitConf, true)
-					if err != nil {
-						return fmt.Errorf("failed to discover application git URL: %w", err)
-					}
-					if o.AppGitURL == "" {
-						return fmt.Errorf("could not to discover application git URL")
+			for _, app := range apps {
+				r, err := o.newPromoteRule(dir, promoteConfig, app, multipleApps)
+				if err != nil {
+					return err
+				}
+
+				if generateChangelog {
+					versionFn := factory.NewVersionFunction(r)
+					if versionFn != nil {
+						previousVersion, err := versionFn(r)
+						if err != nil {
+							return fmt.Errorf("failed to find the current version of %s in %s: %w", app.App, env.Key, err)
+						}
+						previousVersions = append(previousVersions, previousVersion)
 					}
 				}
-				r.GitURL = o.AppGitURL
-			}
 
-			fn := factory.NewFunction(r)
-			if fn == nil {
-				return fmt.Errorf("could not create rule function ")
+				end := o.StartSpan("rule", telemetry.PromoteAttributes(env.Key, app.App, app.Version)...)
+				err = applyPromoteRule(r)
+				end(err)
+				if err != nil {
+					return fmt.Errorf("failed to promote %s to %s: %w", app.App, env.Key, err)
+				}
 			}
-			err = fn(r)
+		}
+		o.DirectPush = directPush
+		if generateChangelog {
+			changelog, err := o.GenerateChangelogSince(previousVersions)
 			if err != nil {
-				return fmt.Errorf("failed to promote to %s: %w", env.Key, err)
+				log.Logger().Warnf("failed to generate the changelog for %s: %s", o.Application, err)
+			} else {
+				o.CommitChangelog = changelog
 			}
 		}
-		return nil
+		return o.checkLock()
+	}
+
+	if o.EnvDir != "" {
+		return o.PromoteInDir(o.EnvDir)
 	}
 
 	if releaseInfo.PullRequestInfo != nil {
 		o.PullRequestNumber = releaseInfo.PullRequestInfo.Number
 	}
-	env := envs[0]
+	gitURL, err := o.environmentGitURL(envs[0])
+	if err != nil {
+		return err
+	}
+	autoMerge := o.AutoMerge
+	if draftPR {
+		autoMerge = false
+	}
+	info, err := o.Create(gitURL, envDir, labels, autoMerge)
+	releaseInfo.PullRequestInfo = info
+	if o.PushedCommitSha != "" {
+		releaseInfo.MergeSHA = o.PushedCommitSha
+	}
+	return err
+}
+
+// environmentGitURL returns the git URL of the environment repository
+func (o *Options) environmentGitURL(env *jxcore.EnvironmentConfig) (string, error) {
 	gitURL := requirements.EnvironmentGitURL(o.DevEnvContext.Requirements, env.Key)
 	if gitURL == "" {
 		if env.RemoteCluster {
-			return fmt.Errorf("no git URL for remote cluster %s", env.Key)
+			return "", fmt.Errorf("no git URL for remote cluster %s", env.Key)
 		}
 
 		// lets default to the git repository for the dev environment for local clusters
 		gitURL = requirements.EnvironmentGitURL(o.DevEnvContext.Requirements, "dev")
 		if gitURL == "" {
-			return fmt.Errorf("no git URL for dev environment")
+			return "", fmt.Errorf("no git URL for dev environment")
 		}
 	}
-	autoMerge := o.AutoMerge
-	if draftPR {
-		autoMerge = false
+	return gitURL, nil
+}
+
+// newPromoteRule creates the rule to promote the app into the environment repository in the given dir
+func (o *Options) newPromoteRule(dir string, promoteConfig *v1alpha1.Promote, app ManifestApp, multipleApps bool) (*rules.PromoteRule, error) {
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			GitURL:            "",
+			Version:           app.Version,
+			AppName:           app.App,
+			ChartAlias:        app.Alias,
+			Namespace:         o.Namespace,
+			HelmRepositoryURL: app.HelmRepoURL,
+			ReleaseName:       app.ReleaseName,
+		},
+		Dir:           dir,
+		Config:        *promoteConfig,
+		DevEnvContext: &o.DevEnvContext,
 	}
-	info, err := o.Create(gitURL, envDir, labels, autoMerge)
-	releaseInfo.PullRequestInfo = info
-	return err
+
+	// lets check if we need the apps git URL
+	if promoteConfig.Spec.FileRule != nil || promoteConfig.Spec.KptRule != nil {
+		if multipleApps {
+			return nil, fmt.Errorf("cannot promote multiple apps from a manifest using file or kpt rules as they require the git URL of each app")
+		}
+		err := o.discoverAppGitURL()
+		if err != nil {
+			return nil, err
+		}
+		r.GitURL = o.AppGitURL
+	}
+	return r, nil
+}
+
+// applyPromoteRule applies the promote rule to the environment repository
+func applyPromoteRule(r *rules.PromoteRule) error {
+	fn := factory.NewFunction(r)
+	if fn == nil {
+		return fmt.Errorf("could not create rule function ")
+	}
+	return fn(r)
+}
+
+// PromoteInDir applies the promotion function to an environment git repository which is already checked out in the
+// given directory and optionally commits the changes. No Pull Request is created
+func (o *Options) PromoteInDir(dir string) error {
+	exists, err := files.DirExists(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check if dir exists %s: %w", dir, err)
+	}
+	if !exists {
+		return fmt.Errorf("environment dir %s does not exist", dir)
+	}
+	o.OutDir = dir
+	err = o.Function()
+	if err != nil {
+		return fmt.Errorf("failed to invoke change function in dir %s: %w", dir, err)
+	}
+	if !o.EnvDirCommit {
+		log.Logger().Infof("promoted to environment dir %s without committing", termcolor.ColorInfo(dir))
+		return nil
+	}
+
+	gitter := o.Git()
+	_, _, err = gitclient.EnsureUserAndEmailSetup(gitter, dir, "", "")
+	if err != nil {
+		return fmt.Errorf("failed to setup git user and email: %w", err)
+	}
+	commitMessage := o.CommitTitle + "\n\n" + o.CommitMessage
+	if o.CommitChangelog != "" {
+		commitMessage += "\n\n" + o.ChangelogSeparator + "\n" + o.CommitChangelog
+	}
+	changed, err := gitclient.AddAndCommitFiles(gitter, dir, strings.TrimSpace(commitMessage))
+	if err != nil {
+		return fmt.Errorf("failed to commit changes in dir %s: %w", dir, err)
+	}
+	if !changed {
+		log.Logger().Infof("no changes detected in environment dir %s", termcolor.ColorInfo(dir))
+		return nil
+	}
+	log.Logger().Infof("committed the promotion in environment dir %s", termcolor.ColorInfo(dir))
+	return nil
+}
+
+// lazyLoadEnvDir lazily loads the EnvironmentContext from the --env-dir directory rather than from the cluster and the
+// development git repository. Any environments to promote to which are not in the requirements are defaulted
+func (o *Options) lazyLoadEnvDir() error {
+	e := &o.DevEnvContext
+	if e.DevEnv == nil {
+		e.DevEnv = jxenv.CreateDefaultDevEnvironment(o.Namespace)
+	}
+	if e.Requirements == nil {
+		fileName := filepath.Join(o.EnvDir, jxcore.RequirementsConfigFileName)
+		exists, err := files.FileExists(fileName)
+		if err != nil {
+			return fmt.Errorf("failed to check if file exists %s: %w", fileName, err)
+		}
+		if exists {
+			req, err := jxcore.LoadRequirementsConfigFile(fileName, false)
+			if err != nil {
+				return fmt.Errorf("failed to load requirements file %s: %w", fileName, err)
+			}
+			e.Requirements = &req.Spec
+		} else {
+			e.Requirements = &jxcore.NewRequirementsConfig().Spec
+		}
+	}
+	if e.VersionResolver == nil {
+		// cluster git repositories include the version stream
+		e.VersionResolver = &versionstream.VersionResolver{
+			VersionsDir: filepath.Join(o.EnvDir, "versionStream"),
+		}
+	}
+	for _, name := range o.Environments {
+		exists := slices.ContainsFunc(e.Requirements.Environments, func(env jxcore.EnvironmentConfig) bool {
+			return env.Key == name
+		})
+		if !exists {
+			e.Requirements.Environments = append(e.Requirements.Environments, jxcore.EnvironmentConfig{Key: name})
+		}
+	}
+	return nil
 }
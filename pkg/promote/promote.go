@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jenkins-x/jx-helpers/v3/pkg/requirements"
@@ -17,7 +19,16 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/git/setup"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+	promoteclient "github.com/jenkins-x-plugins/jx-promote/pkg/client/clientset/versioned"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/cloudevents"
 	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/lease"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/notify"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/policy"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/telemetry"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/webhook"
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/builds"
@@ -49,6 +60,7 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/trace"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -89,6 +101,7 @@ type Options struct {
 	NoMergePullRequest  bool
 	NoPoll              bool
 	NoWaitAfterMerge    bool
+	EnvDirCommit        bool
 	NoGroupPullRequest  bool
 	IgnoreLocalFiles    bool
 	DisableGitConfig    bool //  to disable git init in unit tests
@@ -98,12 +111,38 @@ type Options struct {
 	Filter              string
 	Alias               string
 	AddChangelog        string
+	GenerateChangelog   bool
+	FromManifest        string
+	FromEnvironment     string
+	Output              string
+	EnvDir              string
+	OutputFile          string
+	MergeMethod         string
+	MergeCommitTemplate string
+	NativeAutoMerge     bool
+	RequiredChecks      []string
+	VerifyRollout       bool
+	RollbackOnFailure   bool
+	RollbackAutoMerge   bool
+	OverrideFreeze      string
+	CloudEventsSink     string
+	Parallel            bool
+	Lock                bool
+	NoResume            bool
+	LockTTL             string
+	WebhookListen       string
+	WebhookSecret       string
+	WebhookPollTime     string
+	Author              string
+	PolicyLabels        map[string]string
+	StatusResource      string
 
 	KubeClient kubernetes.Interface
 	JXClient   versioned.Interface
 	Helmer     helm.Helmer
 	Input      input.Interface
 	GitClient  gitclient.Interface
+	HTTPClient *http.Client
 
 	// calculated fields
 	TimeoutDuration         *time.Duration
@@ -112,6 +151,29 @@ type Options struct {
 	GitInfo                 *giturl.GitRepository
 	releaseResource         *v1.Release
 	ReleaseInfo             *ReleaseInfo
+	ManifestApps            []ManifestApp
+	Results                 PromoteResults
+	requiredChecks          []string
+	smokeChecks             map[string][]v1alpha1.SmokeCheck
+	freezeNote              string
+	policyNote              string
+	lock                    *lease.Lock
+	activityLock            *sync.Mutex
+	webhooks                *webhook.Listener
+	webhookPollDuration     *time.Duration
+
+	// DevPromoteConfig the promote configuration in the development environment git repository
+	DevPromoteConfig *v1alpha1.Promote
+
+	// PullRequestHandlers overrides the default handlers of the states of the promotion Pull Request while waiting for it to merge
+	PullRequestHandlers map[PullRequestState]PullRequestHandler
+
+	// Clock the clock used when polling the promotion Pull Request which defaults to the real time. It is shared by the
+	// groups promoted with --parallel so must be safe for concurrent use
+	Clock Clock
+
+	// PromoteClient the client of the Promote and Promotion resources
+	PromoteClient promoteclient.Interface
 
 	// Used for testing
 	CloneDir string
@@ -121,7 +183,26 @@ type ReleaseInfo struct {
 	ReleaseName     string
 	FullAppName     string
 	Version         string
+	Apps            []ManifestApp
 	PullRequestInfo *scm.PullRequest
+
+	// State the current state of the promotion
+	State PromoteState
+
+	// MergeSHA the merge commit SHA of the Pull Request once merged
+	MergeSHA string
+
+	// PipelineActivity the name of the PipelineActivity recording the promotion
+	PipelineActivity string
+
+	// Environments the environments being promoted to
+	Environments []*jxcore.EnvironmentConfig
+
+	// RollbackPullRequest the Pull Request created to rollback a failed promotion
+	RollbackPullRequest *scm.PullRequest
+
+	// PolicyDecisions the decisions of the policies evaluated for each environment
+	PolicyDecisions map[string][]policy.Decision
 }
 
 var (
@@ -147,6 +228,12 @@ var (
 		# To promote a postgres chart using an alias
 		jx promote -f postgres --alias mydb
 
+		# To promote many applications together in a single Pull Request per environment
+		jx promote --from-manifest apps.yaml --env staging
+
+		# To promote into an environment git repository which is already checked out locally and commit the change
+		jx promote --version 1.2.3 --env staging --env-dir ./environment-staging --env-dir-commit
+
 		# To create or update a Preview Environment please see the 'jx preview' command if you are inside a git clone of a repo
 		jx preview
 	`)
@@ -189,10 +276,17 @@ func (o *Options) AddOptions(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&o.Version, "version", "v", "", "The Version to promote. If no version is specified it defaults to $VERSION which is usually populated in a pipeline. If no value can be found you will be prompted to pick the version")
 	cmd.Flags().StringVarP(&o.VersionFile, "version-file", "", "", "the file to load the version from if not specified directly or via a $VERSION environment variable. Defaults to VERSION in the current dir")
 	cmd.Flags().StringVarP(&o.AddChangelog, "add-changelog", "c", "", "a file to take a changelog from to add to the pullr equest body. Typically a file generated by jx changelog.")
+	cmd.Flags().BoolVarP(&o.GenerateChangelog, "generate-changelog", "", false, "generates a changelog of the commits in the application git repository since the version currently in the environment to add to the pull request body. Ignored if --add-changelog is specified")
 	cmd.Flags().StringVarP(&o.ChangelogSeparator, "changelog-separator", "", os.Getenv("CHANGELOG_SEPARATOR"), "the separator to use between commit message and changelog in the pull request body. Default to ----- or if set the CHANGELOG_SEPARATOR environment variable")
 	cmd.Flags().StringVarP(&o.LocalHelmRepoName, "helm-repo-name", "r", kube.LocalHelmRepoName, "The name of the helm repository that contains the app")
 	cmd.Flags().StringVarP(&o.HelmRepositoryURL, "helm-repo-url", "u", "", "The Helm Repository URL to use for the App")
 	cmd.Flags().StringVarP(&o.ReleaseName, "release", "", "", "The name of the helm release")
+	cmd.Flags().StringVarP(&o.Output, optionOutput, "o", "", "The format to output the result of the promotion in once complete. Supported values: json, yaml")
+	cmd.Flags().StringVarP(&o.OutputFile, "output-file", "", "", "The file to write the result of the promotion to if using --output. Defaults to standard output")
+	cmd.Flags().StringVarP(&o.EnvDir, "env-dir", "", "", "The directory of an already checked out environment git repository to promote into directly without creating a Pull Request")
+	cmd.Flags().BoolVarP(&o.EnvDirCommit, "env-dir-commit", "", false, "Commits the changes made to the --env-dir directory")
+	cmd.Flags().StringVarP(&o.FromEnvironment, optionFromEnvironment, "", "", "The environment to promote from. The version, chart repository and release name of the app currently in the environment are promoted")
+	cmd.Flags().StringVarP(&o.FromManifest, "from-manifest", "", "", "A YAML file listing the apps and versions to promote together in a single Pull Request per environment")
 	cmd.Flags().StringVarP(&o.Timeout, optionTimeout, "t", "1h", "The timeout to wait for the promotion to succeed in the underlying Environment. The command fails if the timeout is exceeded or the promotion does not complete")
 	cmd.Flags().StringVarP(&o.PullRequestPollTime, optionPullRequestPollTime, "", "20s", "Poll time when waiting for a Pull Request to merge")
 	cmd.Flags().StringVarP(&o.DevEnvContext.GitUsername, "git-user", "", "", "Git username used to clone the development environment. If not specified its loaded from the git credentials file")
@@ -200,9 +294,28 @@ func (o *Options) AddOptions(cmd *cobra.Command) {
 
 	cmd.Flags().BoolVarP(&o.NoHelmUpdate, "no-helm-update", "", false, "Allows the 'helm repo update' command if you are sure your local helm cache is up to date with the version you wish to promote")
 	cmd.Flags().BoolVarP(&o.NoMergePullRequest, "no-merge", "", false, "Disables automatic merge of promote Pull Requests")
+	cmd.Flags().StringVarP(&o.MergeMethod, optionMergeMethod, "", "", "The method used to merge promote Pull Requests. Supported values: merge, squash, rebase. Defaults to the git providers default")
+	cmd.Flags().StringVarP(&o.MergeCommitTemplate, "merge-commit-template", "", DefaultMergeCommitTemplate, "The go template of the commit title when merging promote Pull Requests. Can use {{.App}}, {{.Version}}, {{.Environment}}, {{.PullRequestNumber}} and {{.PullRequestTitle}}")
+	cmd.Flags().StringArrayVarP(&o.RequiredChecks, "required-check", "", nil, "The name of a commit status check which must succeed before merging promote Pull Requests. If specified any other checks are ignored. Can also be specified per environment via 'requiredChecks' in the '.jx/promote.yaml' file of the environment repository")
+	cmd.Flags().BoolVarP(&o.NativeAutoMerge, optionNativeAutoMerge, "", false, "Enables the git providers native auto merge or merge queue on promote Pull Requests and exits rather than polling for the Pull Request to merge. Cannot be used with --verify-rollout or --rollback-on-failure and is ignored for environments with smoke checks")
 
 	cmd.Flags().BoolVarP(&o.NoPoll, "no-poll", "", false, "Disables polling for Pull Request or Pipeline status")
+	cmd.Flags().BoolVarP(&o.Parallel, optionParallel, "", false, "Promotes to each group of environments concurrently rather than waiting for the promotion to one group to complete before starting the next. Groups wait for any groups containing the upstream environments of their environments")
+	cmd.Flags().BoolVarP(&o.Lock, optionLock, "", false, "Acquires a lock of the environment git repository using a Lease in the development namespace before creating the Pull Request which is held until it merges so that concurrent promotions do not race to merge. The promotion is aborted if the lock is lost")
+	cmd.Flags().StringVarP(&o.LockTTL, optionLockTTL, "", "2m", "The duration of the --lock Lease after which another promotion can take it over if it is not renewed")
+	cmd.Flags().StringVarP(&o.WebhookListen, optionWebhookListen, "", "", "The address such as ':8080' to listen on for pull_request and status webhooks of the promotion Pull Requests from the git provider or forwarded by lighthouse. Waiting for a Pull Request to merge is driven by these webhooks with polling every --webhook-poll-time as a fallback")
+	cmd.Flags().StringVarP(&o.WebhookSecret, optionWebhookSecret, "", os.Getenv("HMAC_TOKEN"), "The HMAC token used to validate the webhooks received via --webhook-listen which requires it. Defaults to the $HMAC_TOKEN environment variable")
+	cmd.Flags().StringVarP(&o.WebhookPollTime, optionWebhookPollTime, "", "5m", "Poll time when waiting for a Pull Request to merge if listening for webhooks via --webhook-listen")
 	cmd.Flags().BoolVarP(&o.NoGroupPullRequest, "no-pr-group", "", false, "Disables grouping Auto promotions to different Environments in the same git repository within a single Pull Request which causes them to use separate Pull Requests")
+	cmd.Flags().BoolVarP(&o.VerifyRollout, optionVerifyRollout, "", false, "Waits after the Pull Request is merged for the promoted releases to be deployed at the promoted version with their Deployments and StatefulSets rolled out and pods ready in the environment namespace. Respects the --timeout option")
+	cmd.Flags().BoolVarP(&o.RollbackOnFailure, optionRollbackOnFailure, "", false, "Creates a Pull Request to restore the previous versions in the environment if the rollout verification or smoke checks fail after the promotion merges")
+	cmd.Flags().BoolVarP(&o.RollbackAutoMerge, "rollback-auto-merge", "", false, "Enables auto merge of the rollback Pull Request created by --rollback-on-failure")
+	cmd.Flags().StringVarP(&o.OverrideFreeze, optionOverrideFreeze, "", "", "The reason for promoting outside of the promotion window or during a change freeze of an environment. The reason is recorded on the Pull Request")
+	cmd.Flags().StringVarP(&o.Author, "author", "", "", "The author of the change being promoted used when evaluating promotion policies if it cannot be found from the latest commit in the source directory")
+	cmd.Flags().StringToStringVarP(&o.PolicyLabels, "policy-label", "", nil, "A label of the release in the form 'key=value' used when evaluating promotion policies such as 'security-scan=passed'. Labels of the Release resource take precedence")
+	cmd.Flags().StringVarP(&o.CloudEventsSink, "cloudevents-sink", "", os.Getenv("K_SINK"), "The URL to send CloudEvents to for each promotion state transition. Defaults to the $K_SINK environment variable")
+	cmd.Flags().StringVarP(&o.StatusResource, optionStatusResource, "", "", "The name of the Promote resource in the development namespace to record the last promotion of the app to each environment in its status. The resource is created if it does not exist")
+	cmd.Flags().BoolVarP(&o.NoResume, optionNoResume, "", false, "Disables resuming a promotion recorded in the PipelineActivity by a previous run of the pipeline such as when its pod was killed while waiting for the Pull Request to merge. By default the promotion re-attaches to the existing Pull Request rather than promoting again")
 	cmd.Flags().BoolVarP(&o.NoWaitAfterMerge, "no-wait", "", false, "Disables waiting for completing promotion after the Pull request is merged")
 	cmd.Flags().BoolVarP(&o.IgnoreLocalFiles, "ignore-local-file", "", false, "Ignores the local file system when deducing the Git repository")
 	cmd.Flags().BoolVarP(&o.AutoMerge, "auto-merge", "", false, "If enabled add the 'updatebot' label to tell lighthouse to eagerly merge. Usually the Pull Request pipeline will add this label during the Pull Request pipeline after any extra generation/commits have been done and the PR is valid")
@@ -305,13 +418,16 @@ func (o *Options) Validate() error {
 		o.Input = survey.NewInput()
 	}
 	var err error
-	o.KubeClient, o.Namespace, err = kube.LazyCreateKubeClientAndNamespace(o.KubeClient, o.Namespace)
-	if err != nil {
-		return fmt.Errorf("failed to create the kube client: %w", err)
-	}
-	o.JXClient, err = jxclient.LazyCreateJXClient(o.JXClient)
-	if err != nil {
-		return fmt.Errorf("failed to create the jx client: %w", err)
+	if o.EnvDir == "" {
+		// promoting into an already checked out environment does not use the cluster
+		o.KubeClient, o.Namespace, err = kube.LazyCreateKubeClientAndNamespace(o.KubeClient, o.Namespace)
+		if err != nil {
+			return fmt.Errorf("failed to create the kube client: %w", err)
+		}
+		o.JXClient, err = jxclient.LazyCreateJXClient(o.JXClient)
+		if err != nil {
+			return fmt.Errorf("failed to create the jx client: %w", err)
+		}
 	}
 	if o.VersionFile == "" {
 		o.VersionFile = filepath.Join(o.Dir, "VERSION")
@@ -319,64 +435,51 @@ func (o *Options) Validate() error {
 	if o.ChangelogSeparator == "" {
 		o.ChangelogSeparator = "-----"
 	}
-	return nil
+	err = o.validateMerge()
+	if err != nil {
+		return err
+	}
+	err = o.validateFromEnvironment()
+	if err != nil {
+		return err
+	}
+	if o.Parallel && o.EnvDir != "" {
+		return fmt.Errorf("cannot specify both --%s and --env-dir", optionParallel)
+	}
+	return o.validateOutput()
 }
 
 // Run implements this command
 func (o *Options) Run() error {
+	return o.traceCommand("promote", o.run)
+}
+
+func (o *Options) run() error {
 	err := o.Validate()
 	if err != nil {
 		return fmt.Errorf("failed to validate options: %w", err)
 	}
 
-	// TODO move to validate
-	err = o.EnsureApplicationNameIsDefined(o.SearchForChart, o.DiscoverAppName, o.ChooseChart)
-	if err != nil {
-		return err
-	}
-
-	if o.Version == "" {
-		exists, err := files.FileExists(o.VersionFile)
+	if o.FromManifest != "" {
+		manifest, err := LoadManifest(o.FromManifest)
 		if err != nil {
-			return fmt.Errorf("failed to check for file %s: %w", o.VersionFile, err)
-		}
-		if exists {
-			data, err := os.ReadFile(o.VersionFile)
-			if err != nil {
-				return fmt.Errorf("failed to read version file %s: %w", o.VersionFile, err)
-			}
-			o.Version = strings.TrimSpace(string(data))
-		}
-		if o.Version != "" {
-			log.Logger().Infof("defaulting to the version %s from file %s", termcolor.ColorInfo(o.Version), termcolor.ColorInfo(o.VersionFile))
+			return err
 		}
-		if o.Version == "" {
-			o.Version = os.Getenv("VERSION")
-			if o.Version != "" {
-				log.Logger().Infof("defaulting to the version %s from $VERSION", termcolor.ColorInfo(o.Version))
-			}
+		o.ManifestApps = manifest.Apps
+	} else if o.FromEnvironment != "" {
+		err = o.EnsureApplicationNameIsDefined(o.SearchForChart, o.DiscoverAppName, o.ChooseChart)
+		if err != nil {
+			return err
 		}
-	}
-	if o.Version == "" && o.Application != "" {
-		if o.Interactive {
-			versions, err := o.getAllVersions(o.Application)
-			if err != nil {
-				return fmt.Errorf("failed to get app versions: %w", err)
-			}
-			o.Version, err = o.Input.PickNameWithDefault(versions, "Pick version:", "", "please select a version")
-			if err != nil {
-				return fmt.Errorf("failed to pick a version: %w", err)
-			}
-		} else {
-			o.Version, err = o.findLatestVersion(o.Application)
-			if err != nil {
-				return fmt.Errorf("failed to find latest version of app %s: %w", o.Application, err)
-			}
+	} else {
+		err = o.resolveApplicationAndVersion()
+		if err != nil {
+			return err
 		}
 	}
 
 	ns := o.Namespace
-	if ns == "" {
+	if ns == "" && o.EnvDir == "" {
 		return fmt.Errorf("no namespace defined")
 	}
 	jxClient := o.JXClient
@@ -384,11 +487,29 @@ func (o *Options) Run() error {
 		o.GitClient = cli.NewCLIClient("", o.CommandRunner)
 	}
 
-	err = o.DevEnvContext.LazyLoad(o.GitClient, o.JXClient, o.Namespace, o.Git(), o.Dir)
+	if o.EnvDir != "" {
+		err = o.lazyLoadEnvDir()
+	} else {
+		err = o.lazyLoad()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to lazy load the EnvironmentContext: %w", err)
 	}
 
+	if o.DevPromoteConfig == nil {
+		o.DevPromoteConfig, err = promoteconfig.LoadDevPromote(o.DevEnvContext.DevDir)
+		if err != nil {
+			return fmt.Errorf("failed to load the promote configuration of the dev environment: %w", err)
+		}
+	}
+
+	if o.FromEnvironment != "" {
+		err = o.ResolveFromEnvironment()
+		if err != nil {
+			return fmt.Errorf("failed to resolve the version from environment %s: %w", o.FromEnvironment, err)
+		}
+	}
+
 	if kube.IsInCluster() && !o.DisableGitConfig {
 		err = o.InitGitConfigAndUser()
 		if err != nil {
@@ -396,6 +517,9 @@ func (o *Options) Run() error {
 		}
 	}
 
+	if o.HelmRepositoryURL == "" && o.EnvDir != "" {
+		o.HelmRepositoryURL = o.DefaultChartRepositoryURL()
+	}
 	if o.HelmRepositoryURL == "" {
 		o.HelmRepositoryURL, err = o.ResolveChartRepositoryURL()
 		if err != nil {
@@ -431,34 +555,108 @@ func (o *Options) Run() error {
 		}
 		o.TimeoutDuration = &duration
 	}
+	err = o.validateWebhookOptions()
+	if err != nil {
+		return err
+	}
 
 	if err != nil {
 		return err
 	}
 
-	o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+	if jxClient != nil {
+		o.Activities = jxClient.JenkinsV1().PipelineActivities(ns)
+	}
 
 	if o.ReleaseName == "" {
 		o.ReleaseName = o.Application
 	}
 
-	if len(o.Environments) > 0 {
-		return o.PromoteAll(func(env *jxcore.EnvironmentConfig) bool {
+	var pred func(*jxcore.EnvironmentConfig) bool
+	switch {
+	case len(o.Environments) > 0:
+		pred = func(env *jxcore.EnvironmentConfig) bool {
 			return Contains(o.Environments, env.Key)
-		})
+		}
+	case o.All:
+		pred = func(env *jxcore.EnvironmentConfig) bool {
+			return (env.PromotionStrategy == v1.PromotionStrategyTypeAutomatic || env.PromotionStrategy == v1.PromotionStrategyTypeManual) && envIsPermanent(env)
+		}
+	case o.AllAutomatic:
+		pred = func(env *jxcore.EnvironmentConfig) bool {
+			return env.PromotionStrategy == v1.PromotionStrategyTypeAutomatic && envIsPermanent(env)
+		}
+	default:
+		return fmt.Errorf("in bach mode one option needs to specified of: --%s, --all and --all-auto", optionEnvironment)
 	}
 
-	if o.All {
-		return o.PromoteAll(func(env *jxcore.EnvironmentConfig) bool {
-			return (env.PromotionStrategy == v1.PromotionStrategyTypeAutomatic || env.PromotionStrategy == v1.PromotionStrategyTypeManual) && envIsPermanent(env)
-		})
+	stopWebhooks, err := o.startWebhookListener()
+	if err != nil {
+		return err
 	}
-	if o.AllAutomatic {
-		return o.PromoteAll(func(env *jxcore.EnvironmentConfig) bool {
-			return env.PromotionStrategy == v1.PromotionStrategyTypeAutomatic && envIsPermanent(env)
-		})
+	defer stopWebhooks()
+
+	trace.SpanFromContext(o.TraceContext).SetAttributes(telemetry.PromoteAttributes("", o.Application, o.Version)...)
+	err = o.PromoteAll(pred)
+	err2 := o.WriteResults()
+	err3 := o.RecordStatus()
+	if err3 != nil {
+		log.Logger().Warnf("failed to record the status of the promotion: %s", err3.Error())
+	}
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("in bach mode one option needs to specified of: --%s, --all and --all-auto", optionEnvironment)
+	return err2
+}
+
+// resolveApplicationAndVersion defaults the application name and version to promote if they are not specified
+func (o *Options) resolveApplicationAndVersion() error {
+	// TODO move to validate
+	err := o.EnsureApplicationNameIsDefined(o.SearchForChart, o.DiscoverAppName, o.ChooseChart)
+	if err != nil {
+		return err
+	}
+
+	if o.Version == "" {
+		exists, err := files.FileExists(o.VersionFile)
+		if err != nil {
+			return fmt.Errorf("failed to check for file %s: %w", o.VersionFile, err)
+		}
+		if exists {
+			data, err := os.ReadFile(o.VersionFile)
+			if err != nil {
+				return fmt.Errorf("failed to read version file %s: %w", o.VersionFile, err)
+			}
+			o.Version = strings.TrimSpace(string(data))
+		}
+		if o.Version != "" {
+			log.Logger().Infof("defaulting to the version %s from file %s", termcolor.ColorInfo(o.Version), termcolor.ColorInfo(o.VersionFile))
+		}
+		if o.Version == "" {
+			o.Version = os.Getenv("VERSION")
+			if o.Version != "" {
+				log.Logger().Infof("defaulting to the version %s from $VERSION", termcolor.ColorInfo(o.Version))
+			}
+		}
+	}
+	if o.Version == "" && o.Application != "" {
+		if o.Interactive {
+			versions, err := o.getAllVersions(o.Application)
+			if err != nil {
+				return fmt.Errorf("failed to get app versions: %w", err)
+			}
+			o.Version, err = o.Input.PickNameWithDefault(versions, "Pick version:", "", "please select a version")
+			if err != nil {
+				return fmt.Errorf("failed to pick a version: %w", err)
+			}
+		} else {
+			o.Version, err = o.findLatestVersion(o.Application)
+			if err != nil {
+				return fmt.Errorf("failed to find latest version of app %s: %w", o.Application, err)
+			}
+		}
+	}
+	return nil
 }
 
 func envIsPermanent(env *jxcore.EnvironmentConfig) bool {
@@ -574,26 +772,62 @@ func (o *Options) PromoteAll(pred func(*jxcore.EnvironmentConfig) bool) error {
 		}
 		groups = append(groups, []*jxcore.EnvironmentConfig{env})
 	}
-	for _, group := range groups {
-		firstEnv := group[0]
-
-		// lets clear the branch name so that we create a new branch for each PR...
-		o.BranchName = ""
-		releaseInfo, err := o.Promote(group, false, o.NoPoll)
+	if o.Parallel && len(groups) > 1 {
+		return o.promoteGroupsInParallel(groups)
+	}
+	for i, group := range groups {
+		err := o.promoteGroup(group)
 		if err != nil {
+			o.addSkippedResults(groups[i+1:])
 			return err
 		}
-		o.ReleaseInfo = releaseInfo
-		if !o.NoPoll {
-			err = o.WaitForPromotion(firstEnv, releaseInfo)
-			if err != nil {
-				return err
-			}
-		}
 	}
 	return nil
 }
 
+// promoteGroup promotes to the group of environments sharing a Pull Request and waits for the promotion to complete
+func (o *Options) promoteGroup(group []*jxcore.EnvironmentConfig) error {
+	firstEnv := group[0]
+
+	// lets clear the branch name so that we create a new branch for each PR...
+	o.BranchName = ""
+	err := o.acquireLock(group)
+	if err != nil {
+		return err
+	}
+	defer o.releaseLock()
+
+	attrs := o.spanAttributes(group)
+	end := o.StartSpan("promote environments", attrs...)
+	releaseInfo, err := o.Promote(group, false, o.NoPoll)
+	end(err)
+	if err != nil {
+		// lets record why the promotion failed such as a freeze or policy denial
+		if releaseInfo == nil {
+			releaseInfo = o.newReleaseInfo(o.PromoteApps(), group)
+		}
+		releaseInfo.State = PromoteStateFailed
+		o.addResults(group, releaseInfo)
+		return err
+	}
+	o.ReleaseInfo = releaseInfo
+	if !o.NoPoll && o.EnvDir == "" {
+		end = o.StartSpan("wait for promotion", attrs...)
+		err = o.WaitForPromotion(firstEnv, releaseInfo)
+		end(err)
+	}
+	o.addResults(group, releaseInfo)
+	return err
+}
+
+// fullAppName returns the app name prefixed with the local helm repository name
+func (o *Options) fullAppName(app string) string {
+	if app == "" || o.LocalHelmRepoName == "" {
+		return app
+	}
+	return o.LocalHelmRepoName + "/" + app
+}
+
 // EnvironmentNamespace returns the namespace for the environment
 func EnvironmentNamespace(env *jxcore.EnvironmentConfig) string {
 	ns := env.Namespace
@@ -607,12 +841,12 @@ func (o *Options) Promote(envs []*jxcore.EnvironmentConfig, warnIfAuto, noPoll b
 	if len(envs) == 0 {
 		return nil, nil
 	}
-	app := o.Application
-	if app == "" {
+	apps := o.PromoteApps()
+	if len(apps) == 0 {
 		log.Logger().Warnf("No application name could be detected so cannot promote via Helm. If the detection of the helm chart name is not working consider adding it with the --%s argument on the 'jx promote' command", optionApplication)
 		return nil, nil
 	}
-	version := o.Version
+	app := joinApps(apps, func(a *ManifestApp) string { return a.App })
 	info := termcolor.ColorInfo
 
 	var targetNamespaces []string
@@ -622,23 +856,43 @@ func (o *Options) Promote(envs []*jxcore.EnvironmentConfig, warnIfAuto, noPoll b
 			targetNamespaces = append(targetNamespaces, targetNS)
 		}
 	}
-	if version == "" {
-		log.Logger().Infof("Promoting latest version of app %s to namespace %s", info(app), info(strings.Join(targetNamespaces, " ")))
-	} else {
-		log.Logger().Infof("Promoting app %s version %s to namespace %s", info(app), info(version), info(strings.Join(targetNamespaces, " ")))
+	for _, a := range apps {
+		if a.Version == "" {
+			log.Logger().Infof("Promoting latest version of app %s to namespace %s", info(a.App), info(strings.Join(targetNamespaces, " ")))
+		} else {
+			log.Logger().Infof("Promoting app %s version %s to namespace %s", info(a.App), info(a.Version), info(strings.Join(targetNamespaces, " ")))
+		}
 	}
 
-	fullAppName := app
-	if o.LocalHelmRepoName != "" {
-		fullAppName = o.LocalHelmRepoName + "/" + app
-	}
-	if o.ReleaseName == "" {
+	if o.ReleaseName == "" && len(o.ManifestApps) == 0 {
 		o.ReleaseName = app
 	}
-	releaseInfo := &ReleaseInfo{
-		ReleaseName: o.ReleaseName,
-		FullAppName: fullAppName,
-		Version:     version,
+	releaseInfo := o.newReleaseInfo(apps, envs)
+	if o.EnvDir != "" {
+		// there is no cluster or Pull Request to check or record the promotion in when promoting into a local directory
+		return releaseInfo, o.PromoteViaPullRequest(envs, releaseInfo, false)
+	}
+
+	resumed, err := o.ResumePromotion(envs[0], releaseInfo, o.CreatePromoteKey(envs[0]))
+	if err != nil {
+		return releaseInfo, fmt.Errorf("failed to resume the promotion: %w", err)
+	}
+	if resumed {
+		return releaseInfo, nil
+	}
+
+	now := time.Now()
+	frozenDraft, err := o.CheckFreeze(envs, now)
+	if err != nil {
+		return releaseInfo, err
+	}
+	policyDraft, err := o.EvaluatePolicies(envs, releaseInfo, now)
+	if err != nil {
+		return releaseInfo, err
+	}
+	err = o.CheckUpstreams(envs, releaseInfo)
+	if err != nil {
+		return releaseInfo, err
 	}
 
 	for _, env := range envs {
@@ -647,29 +901,27 @@ func (o *Options) Promote(envs []*jxcore.EnvironmentConfig, warnIfAuto, noPoll b
 			// lets default the strategy based if its missing from the Environment
 			strategy = v1.PromotionStrategyTypeAutomatic
 		}
-		draftPR := strategy != v1.PromotionStrategyTypeAutomatic
+		draftPR := strategy != v1.PromotionStrategyTypeAutomatic || frozenDraft || policyDraft
 		targetNS := EnvironmentNamespace(env)
 		if targetNS == "" {
-			return nil, fmt.Errorf("no namespace for environment %s", env.Key)
+			return releaseInfo, fmt.Errorf("no namespace for environment %s", env.Key)
 		}
 
 		if warnIfAuto && env != nil && strategy == v1.PromotionStrategyTypeAutomatic && !o.BatchMode {
 			log.Logger().Infof("%s", termcolor.ColorWarning(fmt.Sprintf("WARNING: The Environment %s is setup to promote automatically as part of the CI/CD Pipelines.\n", env.Key)))
 			flag, err := o.Input.Confirm("Do you wish to promote anyway? :", false, "usually we do not manually promote to Auto promotion environments")
 			if err != nil {
-				return nil, fmt.Errorf("failed to confirm promotion: %w", err)
+				return releaseInfo, fmt.Errorf("failed to confirm promotion: %w", err)
 			}
 			if !flag {
 				return releaseInfo, nil
 			}
 		}
 
-		jxClient := o.JXClient
-		kubeClient := o.KubeClient
 		promoteKey := o.CreatePromoteKey(env)
 		if env != nil {
 			if !envIsPermanent(env) {
-				return nil, fmt.Errorf("cannot promote to Environment which is not a permanent Environment")
+				return releaseInfo, fmt.Errorf("cannot promote to Environment which is not a permanent Environment")
 			}
 			o.ReusePullRequest = env.ReusePullRequest
 
@@ -690,8 +942,17 @@ func (o *Options) Promote(envs []*jxcore.EnvironmentConfig, warnIfAuto, noPoll b
 						if pr != nil && pr.Link != "" {
 							p.PullRequestURL = pr.Link
 						}
-						if version != "" && a.Spec.Version == "" {
-							a.Spec.Version = version
+						// the PipelineActivity is for a single version so leave it blank when promoting a manifest of apps
+						if len(apps) == 1 && apps[0].Version != "" && a.Spec.Version == "" {
+							a.Spec.Version = apps[0].Version
+						}
+						if releaseInfo.MergeSHA != "" {
+							// the changes were pushed directly to the base branch so there is no Pull Request to wait for
+							err = activities.CompletePromotionPullRequest(a, s, ps, p)
+							if err != nil {
+								return err
+							}
+							p.MergeCommitSHA = releaseInfo.MergeSHA
 						}
 						if noPoll {
 							p.Status = v1.ActivityStatusTypeSucceeded
@@ -702,18 +963,49 @@ func (o *Options) Promote(envs []*jxcore.EnvironmentConfig, warnIfAuto, noPoll b
 						activities.UpdateStatus(a, false, nil)
 						return nil
 					}
-					err = promoteKey.OnPromotePullRequest(kubeClient, jxClient, o.Namespace, startPromotePR)
+					releaseInfo.PipelineActivity = promoteKey.Name
+					if releaseInfo.PullRequestInfo != nil {
+						releaseInfo.State = PromoteStateCreated
+						o.Notify(notify.EventPullRequestCreated, env, releaseInfo, "")
+					} else if releaseInfo.MergeSHA != "" {
+						releaseInfo.State = PromoteStateMerged
+						o.Notify(notify.EventPullRequestMerged, env, releaseInfo, "")
+					}
+					err = o.onPromotePullRequest(promoteKey, startPromotePR)
 					if err != nil {
 						log.Logger().Warnf("Failed to update PipelineActivity: %s", err)
 					}
-					// lets sleep a little before we try poll for the PR status
-					time.Sleep(waitAfterPullRequestCreated)
+					if releaseInfo.MergeSHA != "" {
+						o.EmitCloudEvent(cloudevents.TypePullRequestMerged, env, releaseInfo, "")
+					} else if releaseInfo.PullRequestInfo != nil {
+						o.EmitCloudEvent(cloudevents.TypePullRequestCreated, env, releaseInfo, "")
+					}
+					if releaseInfo.PullRequestInfo != nil && !noPoll {
+						// lets sleep a little before we try poll for the PR status
+						time.Sleep(waitAfterPullRequestCreated)
+					}
 				}
 				return releaseInfo, err
 			}
 		}
 	}
-	return nil, fmt.Errorf("no source repository URL available on  environment %s", o.Environments)
+	return releaseInfo, fmt.Errorf("no source repository URL available on  environment %s", o.Environments)
+}
+
+// newReleaseInfo returns the details of promoting the apps to the group of environments
+func (o *Options) newReleaseInfo(apps []ManifestApp, envs []*jxcore.EnvironmentConfig) *ReleaseInfo {
+	return &ReleaseInfo{
+		ReleaseName: joinApps(apps, func(a *ManifestApp) string {
+			if a.ReleaseName == "" {
+				return a.App
+			}
+			return a.ReleaseName
+		}),
+		FullAppName:  joinApps(apps, func(a *ManifestApp) string { return o.fullAppName(a.App) }),
+		Version:      joinApps(apps, func(a *ManifestApp) string { return a.Version }),
+		Apps:         apps,
+		Environments: envs,
+	}
 }
 
 // ResolveChartRepositoryURL resolves the current chart repository URL so we can pass it into a remote Environments's
@@ -848,165 +1140,127 @@ func (o *Options) WaitForPromotion(env *jxcore.EnvironmentConfig, releaseInfo *R
 		log.Logger().Infof("No --%s option specified on the 'jx promote' command so not waiting for the promotion to succeed", optionPullRequestPollTime)
 		return nil
 	}
+	if releaseInfo.State == PromoteStateCompleted {
+		return nil
+	}
 	duration := *o.TimeoutDuration
 	end := time.Now().Add(duration)
 
-	jxClient := o.JXClient
-	kubeClient := o.KubeClient
 	pullRequestInfo := releaseInfo.PullRequestInfo
+	nativeAutoMerge := o.NativeAutoMerge
+	if nativeAutoMerge && len(o.smokeChecks[env.Key]) > 0 {
+		log.Logger().Warnf("ignoring --%s as the smoke checks of environment %s need to run after the Pull Request merges", optionNativeAutoMerge, env.Key)
+		nativeAutoMerge = false
+	}
+	if pullRequestInfo != nil && nativeAutoMerge && !o.NoMergePullRequest {
+		err := o.EnableNativeAutoMerge(env, releaseInfo)
+		if err == nil {
+			log.Logger().Infof("the git provider will merge Pull Request %s once its checks pass so not waiting", termcolor.ColorInfo(pullRequestInfo.Link))
+			releaseInfo.State = PromoteStateAutoMerge
+			return nil
+		}
+		log.Logger().Warnf("failed to enable native auto merge so waiting for the Pull Request to merge: %s", err)
+	}
 	if pullRequestInfo != nil {
 		promoteKey := o.CreatePromoteKey(env)
 
 		err := o.waitForGitOpsPullRequest(env, releaseInfo, end, duration, promoteKey)
 		if err != nil {
 			// TODO based on if the PR completed or not fail the PR or the Promote?
-			err2 := promoteKey.OnPromotePullRequest(kubeClient, jxClient, o.Namespace, activities.FailedPromotionPullRequest)
+			err2 := o.onPromotePullRequest(promoteKey, activities.FailedPromotionPullRequest)
+			if releaseInfo.MergeSHA == "" {
+				// failures after merging are emitted when completing the promotion
+				o.EmitCloudEvent(cloudevents.TypePullRequestFailed, env, releaseInfo, err.Error())
+			}
 			if err2 != nil {
 				return err2
 			}
 			return err
 		}
+	} else if releaseInfo.MergeSHA != "" {
+		// the changes were pushed directly to the base branch
+		return o.completePromotion(env, releaseInfo, end, o.CreatePromoteKey(env))
 	}
 	return nil
 }
 
-// TODO This could do with a refactor and some tests...
-func (o *Options) waitForGitOpsPullRequest(env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, end time.Time, duration time.Duration, promoteKey *activities.PromoteStepActivityKey) error {
-	pullRequestInfo := releaseInfo.PullRequestInfo
-	logMergeFailure := false
-	logNoMergeCommitSha := false
-	jxClient := o.JXClient
-	if jxClient == nil {
-		return fmt.Errorf("no jx client")
+// completePromotion completes the promotion once the changes have merged into the environment
+func (o *Options) completePromotion(env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, end time.Time, promoteKey *activities.PromoteStepActivityKey) (err error) {
+	endSpan := o.StartSpan("post-merge update", o.spanAttributes([]*jxcore.EnvironmentConfig{env})...)
+	defer func() {
+		if err != nil && releaseInfo.State == PromoteStateMerged {
+			// the changes merged but the promotion did not complete
+			releaseInfo.State = PromoteStateFailed
+		}
+		endSpan(err)
+	}()
+
+	// the changes have merged so other promotions can proceed
+	o.releaseLock()
+
+	if o.NoWaitAfterMerge {
+		log.Logger().Infof("Pull requests are merged, No wait on promotion to complete")
+		o.recordDeployment(env, releaseInfo, true)
+		return nil
 	}
-	kubeClient := o.KubeClient
-	if kubeClient == nil {
-		return fmt.Errorf("no kube client")
+	err = o.onPromoteUpdate(promoteKey, activities.StartPromotionUpdate)
+	if err != nil {
+		return err
 	}
 
-	scmClient := o.ScmClient
-	if scmClient == nil {
-		return fmt.Errorf("no ScmClient")
+	envs := releaseInfo.Environments
+	if len(envs) == 0 {
+		envs = []*jxcore.EnvironmentConfig{env}
 	}
-
-	ctx := context.Background()
-
-	if pullRequestInfo != nil {
-		fullName := pullRequestInfo.Repository().FullName
-		prNumber := pullRequestInfo.Number
-		for {
-			pr, _, err := scmClient.PullRequests.Find(ctx, fullName, prNumber)
-			if err != nil {
-				return fmt.Errorf("failed to find PR %s %d: %w", fullName, prNumber, err)
-			}
-			if err != nil {
-				log.Logger().Warnf("failed to find PR %s %d: %s", fullName, prNumber, err.Error())
+	if o.VerifyRollout {
+		err = o.WaitForRollout(envs, releaseInfo, end)
+	}
+	if err == nil {
+		err = o.RunSmokeChecks(envs, releaseInfo)
+	}
+	if err != nil {
+		reason := err.Error()
+		if o.RollbackOnFailure {
+			rollbackPR, err2 := o.Rollback(envs, releaseInfo, reason)
+			if err2 != nil {
+				log.Logger().Warnf("failed to rollback the promotion: %s", err2)
 			} else {
-				if pr.Merged {
-					if pr.MergeSha == "" {
-						if !logNoMergeCommitSha {
-							logNoMergeCommitSha = true
-							log.Logger().Infof("Pull Request %s is merged but waiting for Merge SHA", termcolor.ColorInfo(pr.Link))
-						}
-					} else {
-						mergeSha := pr.MergeSha
-						log.Logger().Infof("Pull Request %s is merged at sha %s", termcolor.ColorInfo(pr.Link), termcolor.ColorInfo(mergeSha))
-						mergedPR := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
-							err = activities.CompletePromotionPullRequest(a, s, ps, p)
-							if err != nil {
-								return err
-							}
-							p.MergeCommitSHA = mergeSha
-							return nil
-						}
-						err = promoteKey.OnPromotePullRequest(kubeClient, jxClient, o.Namespace, mergedPR)
-						if err != nil {
-							return err
-						}
-						if o.NoWaitAfterMerge {
-							log.Logger().Infof("Pull requests are merged, No wait on promotion to complete")
-							return err
-						}
-
-						err = promoteKey.OnPromoteUpdate(kubeClient, jxClient, o.Namespace, activities.StartPromotionUpdate)
-						if err != nil {
-							return err
-						}
-
-						err = o.CommentOnIssues(env, promoteKey)
-						if err == nil {
-							err = promoteKey.OnPromoteUpdate(kubeClient, jxClient, o.Namespace, activities.CompletePromotionUpdate)
-						}
-						return err
-					}
-				} else {
-					if pr.Closed {
-						log.Logger().Warnf("Pull Request %s is closed", termcolor.ColorInfo(pr.Link))
-						return fmt.Errorf("promotion failed as Pull Request %s is closed without merging", pr.Link)
-					}
-
-					prLastCommitSha := o.pullRequestLastCommitSha(pr)
-
-					status, err := o.PullRequestLastCommitStatus(pr)
-					switch {
-					case err != nil || status == nil:
-						log.Logger().Warnf("Failed to query the Pull Request last commit status for %s ref %s %s", pr.Link, prLastCommitSha, err)
-					case StateIsPending(status):
-						log.Logger().Info("The build for the Pull Request last commit is currently in progress.")
-					default:
-						switch {
-						case status.State == scm.StateSuccess:
-							if !(o.NoMergePullRequest) {
-								tideMerge := false
-								// Now check if tide is running or not
-								commitStatues, _, err := scmClient.Repositories.ListStatus(ctx, fullName, prLastCommitSha, &scm.ListOptions{})
-								if err != nil {
-									log.Logger().Warnf("unable to get commit statuses for %s", pr.Link)
-								} else {
-									for _, s := range commitStatues {
-										if s.Label == "tide" {
-											tideMerge = true
-											break
-										}
-									}
-								}
-								if !tideMerge {
-									prMergeOptions := &scm.PullRequestMergeOptions{
-										CommitTitle: "jx promote automatically merged promotion PR",
-									}
-									_, err = scmClient.PullRequests.Merge(ctx, fullName, prNumber, prMergeOptions)
-									// TODO: err = gitProvider.MergePullRequest(pr, "jx promote automatically merged promotion PR")
-									if err != nil {
-										if !logMergeFailure {
-											logMergeFailure = true
-											log.Logger().Warnf("Failed to merge the Pull Request %s due to %s maybe I don't have karma?", pr.Link, err)
-										}
-									}
-								}
-							}
-						case StateIsErrorOrFailure(status):
-							return fmt.Errorf("pull request %s last commit has status %s for ref %s", pr.Link, status.State.String(), prLastCommitSha)
-						default:
-							log.Logger().Infof("got git provider status %s from PR %s", status.State.String(), pr.Link)
-						}
-					}
-				}
-				if !pr.Mergeable {
-					log.Logger().Info("Rebasing PullRequest due to conflict")
-
-					err = o.PromoteViaPullRequest([]*jxcore.EnvironmentConfig{env}, releaseInfo, false)
-					if err != nil {
-						return err
-					}
-				}
+				releaseInfo.RollbackPullRequest = rollbackPR
+				reason += " so rolling back via " + rollbackPR.Link
 			}
-			if time.Now().After(end) {
-				return fmt.Errorf("timed out waiting for pull request %s to merge. Waited %s", pr.Link, duration.String())
+		}
+		failedUpdate := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromoteUpdateStep) error {
+			err := activities.FailedPromotionUpdate(a, s, ps, p)
+			if err != nil {
+				return err
 			}
-			time.Sleep(*o.PullRequestPollDuration)
+			p.Description = reason
+			return nil
+		}
+		err2 := o.onPromoteUpdate(promoteKey, failedUpdate)
+		if err2 != nil {
+			log.Logger().Warnf("failed to update the PipelineActivity: %s", err2)
 		}
+		o.EmitCloudEvent(cloudevents.TypePullRequestFailed, env, releaseInfo, reason)
+		o.recordDeployment(env, releaseInfo, false)
+		return err
 	}
-	return nil
+
+	err = o.CommentOnIssues(env, promoteKey)
+	if err == nil {
+		err = o.onPromoteUpdate(promoteKey, activities.CompletePromotionUpdate)
+	}
+	if err == nil {
+		o.EmitCloudEvent(cloudevents.TypePullRequestCompleted, env, releaseInfo, "")
+		o.Notify(notify.EventUpdateCompleted, env, releaseInfo, "")
+		o.recordDeployment(env, releaseInfo, true)
+	}
+	return err
+}
+
+// waitForGitOpsPullRequest waits for the promotion Pull Request to merge using a PullRequestWaiter
+func (o *Options) waitForGitOpsPullRequest(env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, end time.Time, duration time.Duration, promoteKey *activities.PromoteStepActivityKey) error {
+	return o.NewPullRequestWaiter(env, releaseInfo, end, duration, promoteKey).Wait()
 }
 
 func StateIsErrorOrFailure(status *scm.Status) bool {
@@ -1133,6 +1387,13 @@ func (o *Options) CreatePromoteKey(env *jxcore.EnvironmentConfig) *activities.Pr
 		}
 
 		releaseName := o.ReleaseName
+		if apps := o.ManifestApps; len(apps) > 0 {
+			// there is only a single release to record when promoting a single app from a manifest
+			releaseName = ""
+			if len(apps) == 1 {
+				releaseName = apps[0].ReleaseName
+			}
+		}
 		if o.releaseResource == nil && releaseName != "" {
 			jxClient := o.JXClient
 			if err == nil && jxClient != nil {
@@ -1148,7 +1409,7 @@ func (o *Options) CreatePromoteKey(env *jxcore.EnvironmentConfig) *activities.Pr
 		}
 	}
 	if pipeline == "" {
-		pipeline, build = o.GetPipelineName(gitInfo, pipeline, build, o.Application)
+		pipeline, build = o.GetPipelineName(gitInfo, pipeline, build, o.pipelineApp())
 	}
 	if pipeline != "" && build == "" {
 		log.Logger().Warnf("No $BUILD_NUMBER environment variable found so cannot record promotion activities into the PipelineActivity resources in kubernetes")
@@ -1178,6 +1439,15 @@ func (o *Options) CreatePromoteKey(env *jxcore.EnvironmentConfig) *activities.Pr
 	}
 }
 
+// pipelineApp returns the app used to find the pipeline of the promotion which is only known when promoting a single app
+func (o *Options) pipelineApp() string {
+	apps := o.PromoteApps()
+	if len(apps) != 1 {
+		return ""
+	}
+	return apps[0].App
+}
+
 // GetLatestPipelineBuildByCRD returns the latest pipeline build
 func (o *Options) GetLatestPipelineBuildByCRD(pipeline string) (string, error) {
 	// lets find the latest build number
@@ -1284,71 +1554,43 @@ func (o *Options) GetLatestPipelineBuild(pipeline string) (string, string, error
 	return pipeline, build, nil
 }
 
-// CommentOnIssues comments on any issues for a release that the fix is available in the given environment
+// CommentOnIssues comments on any issues for the releases of the promoted apps that the fix is available in the given
+// environment
 func (o *Options) CommentOnIssues(environment *jxcore.EnvironmentConfig, promoteKey *activities.PromoteStepActivityKey) error {
 	ens := EnvironmentNamespace(environment)
 	envName := environment.Key
-	app := o.Application
-	version := o.Version
 	if ens == "" {
 		log.Logger().Warnf("Environment %s has no namespace", envName)
 		return nil
 	}
-	if app == "" {
+	apps := o.PromoteApps()
+	if len(apps) == 0 {
 		log.Logger().Warnf("No application name so cannot comment on issues that they are now in %s", envName)
 		return nil
 	}
-	if version == "" {
-		log.Logger().Warnf("No version name so cannot comment on issues that they are now in %s", envName)
-		return nil
+	for i := range apps {
+		err := o.commentOnAppIssues(environment, promoteKey, apps[i].App, apps[i].Version, len(apps) == 1)
+		if err != nil {
+			return err
+		}
 	}
-	gitInfo := o.GitInfo
-	if gitInfo == nil {
-		log.Logger().Warnf("No GitInfo discovered so cannot comment on issues that they are now in %s", envName)
+	return nil
+}
+
+func (o *Options) commentOnAppIssues(environment *jxcore.EnvironmentConfig, promoteKey *activities.PromoteStepActivityKey, app, version string, singleApp bool) error {
+	ens := EnvironmentNamespace(environment)
+	envName := environment.Key
+	if version == "" {
+		log.Logger().Warnf("No version of app %s so cannot comment on issues that they are now in %s", app, envName)
 		return nil
 	}
 
-	var err error
 	releaseName := naming.ToValidNameWithDots(app + "-" + version)
 	jxClient := o.JXClient
-	kubeClient := o.KubeClient
 
-	appNames := []string{app, o.ReleaseName, ens + "-" + app}
-	svcURL := ""
-	for _, n := range appNames {
-		svcURL, err = services.FindServiceURL(kubeClient, ens, naming.ToValidName(n))
-		if err != nil {
-			return err
-		}
-		if svcURL != "" {
-			break
-		}
-	}
-	if svcURL == "" {
-		log.Logger().Warnf("Could not find the service URL in namespace %s for names %s", ens, strings.Join(appNames, ", "))
-	}
-	available := ""
-	if svcURL != "" {
-		available = fmt.Sprintf(" and available [here](%s)", svcURL)
-	}
-
-	if available == "" {
-		ing, err := kubeClient.ExtensionsV1beta1().Ingresses(ens).Get(context.TODO(), app, metav1.GetOptions{})
-		if err != nil || ing == nil && o.ReleaseName != "" && o.ReleaseName != app {
-			ing, err = kubeClient.ExtensionsV1beta1().Ingresses(ens).Get(context.TODO(), o.ReleaseName, metav1.GetOptions{})
-			if err != nil {
-				return err
-			}
-		}
-		if ing != nil {
-			if len(ing.Spec.Rules) > 0 {
-				hostname := ing.Spec.Rules[0].Host
-				if hostname != "" {
-					available = fmt.Sprintf(" and available at %s", hostname)
-					svcURL = hostname
-				}
-			}
-		}
+	svcURL, available, err := o.FindApplicationURL(ens, app)
+	if err != nil {
+		return err
 	}
 
 	// lets try update the PipelineActivity
@@ -1359,9 +1601,22 @@ func (o *Options) CommentOnIssues(environment *jxcore.EnvironmentConfig, promote
 
 	release, err := jxClient.JenkinsV1().Releases(ens).Get(context.TODO(), releaseName, metav1.GetOptions{})
 	if err == nil && release != nil {
-		o.releaseResource = release
+		if singleApp {
+			o.releaseResource = release
+		}
 		issues := release.Spec.Issues
 
+		// lets use the git repository of the release as the apps of a manifest may come from different repositories
+		fullName := scm.Join(release.Spec.GitOwner, release.Spec.GitRepository)
+		if release.Spec.GitOwner == "" || release.Spec.GitRepository == "" {
+			gitInfo := o.GitInfo
+			if gitInfo == nil {
+				log.Logger().Warnf("No GitInfo discovered so cannot comment on issues that app %s is now in %s", app, envName)
+				return nil
+			}
+			fullName = scm.Join(gitInfo.Organisation, gitInfo.Name)
+		}
+
 		versionMessage := version
 		if release.Spec.ReleaseNotesURL != "" {
 			versionMessage = "[" + version + "](" + release.Spec.ReleaseNotesURL + ")"
@@ -1379,7 +1634,6 @@ func (o *Options) CommentOnIssues(environment *jxcore.EnvironmentConfig, promote
 						log.Logger().Warnf("Could not parse issue id %s for URL %s", id, issue.URL)
 					} else if number > 0 {
 						ctx := context.Background()
-						fullName := scm.Join(gitInfo.Organisation, gitInfo.Name)
 						_, _, err = o.ScmClient.Issues.CreateComment(ctx, fullName, number,
 							&scm.CommentInput{
 								Body: comment,
@@ -1521,3 +1775,49 @@ func ConvertToGitHubPagesURL(repo string) (string, error) {
 	}
 	return fmt.Sprintf("https://%s.github.io/%s/", gitInfo.Organisation, gitInfo.Name), nil
 }
+
+// FindApplicationURL finds the URL of the application in the namespace from its service or ingress. Also returns the
+// markdown text describing where the application is available
+func (o *Options) FindApplicationURL(ens, app string) (string, string, error) {
+	var err error
+	kubeClient := o.KubeClient
+
+	appNames := []string{app, o.ReleaseName, ens + "-" + app}
+	svcURL := ""
+	for _, n := range appNames {
+		svcURL, err = services.FindServiceURL(kubeClient, ens, naming.ToValidName(n))
+		if err != nil {
+			return "", "", err
+		}
+		if svcURL != "" {
+			break
+		}
+	}
+	if svcURL == "" {
+		log.Logger().Warnf("Could not find the service URL in namespace %s for names %s", ens, strings.Join(appNames, ", "))
+	}
+	available := ""
+	if svcURL != "" {
+		available = fmt.Sprintf(" and available [here](%s)", svcURL)
+	}
+
+	if available == "" {
+		ing, err := kubeClient.ExtensionsV1beta1().Ingresses(ens).Get(context.TODO(), app, metav1.GetOptions{})
+		if err != nil || ing == nil && o.ReleaseName != "" && o.ReleaseName != app {
+			ing, err = kubeClient.ExtensionsV1beta1().Ingresses(ens).Get(context.TODO(), o.ReleaseName, metav1.GetOptions{})
+			if err != nil {
+				return "", "", err
+			}
+		}
+		if ing != nil {
+			if len(ing.Spec.Rules) > 0 {
+				hostname := ing.Spec.Rules[0].Host
+				if hostname != "" {
+					available = fmt.Sprintf(" and available at %s", hostname)
+					svcURL = hostname
+				}
+			}
+		}
+	}
+	return svcURL, available, nil
+}
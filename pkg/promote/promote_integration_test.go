@@ -321,6 +321,87 @@ func TestPromoteHelmfileAllAutomaticAndManual(t *testing.T) {
 	assert.Equal(t, v1.ActivityStatusTypeSucceeded, pa.Spec.Status, "pipelineActivity.Spec.Status")
 }
 
+func TestPromoteHelmfileAllAutomaticAndManualInParallel(t *testing.T) {
+	version := "1.2.3"
+	appName := "myapp"
+	ns := "jx"
+
+	runner := NewFakeRunnerWithGitClone()
+
+	_, po := promote.NewCmdPromote()
+	name := "promote-all-parallel"
+	po.DisableGitConfig = true
+	po.Application = appName
+	po.Version = version
+	po.All = true
+	po.Parallel = true
+
+	po.NoPoll = true
+	po.BatchMode = true
+	po.GitKind = "fake"
+	po.CommandRunner = runner.Run
+	po.AppGitURL = "https://github.com/myorg/myapp.git"
+
+	devEnv := jxtesthelpers.CreateTestDevEnvironment(ns)
+	devGitURL := "https://github.com/jenkins-x-labs-bdd-tests/jx3-kubernetes-jenkins"
+	devEnv.Spec.Source.URL = devGitURL
+
+	kubeObjects := []runtime.Object{
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: ns,
+				Labels: map[string]string{
+					"tag":  "",
+					"team": "jx",
+					"env":  "dev",
+				},
+			},
+		},
+	}
+	jxObjects := []runtime.Object{
+		devEnv,
+	}
+
+	po.KubeClient = fake.NewSimpleClientset(kubeObjects...)
+	po.JXClient = v1fake.NewSimpleClientset(jxObjects...)
+	po.Namespace = ns
+	po.Build = "1"
+	po.Pipeline = "myorg/myapp/master"
+	po.DevEnvContext.VersionResolver = jxtesthelpers.CreateTestVersionResolver(t)
+	po.DevEnvContext.Requirements = &jxcore.RequirementsConfig{
+		Environments: []jxcore.EnvironmentConfig{
+			{
+				Key:               "dev",
+				Namespace:         "jx",
+				PromotionStrategy: v1.PromotionStrategyTypeNever,
+				GitURL:            devGitURL,
+			},
+			{
+				Key:               "staging",
+				Namespace:         "jx-staging",
+				PromotionStrategy: v1.PromotionStrategyTypeAutomatic,
+			},
+			{
+				Key:               "production",
+				Namespace:         "jx-production",
+				PromotionStrategy: v1.PromotionStrategyTypeManual,
+			},
+		},
+	}
+
+	err := po.Run()
+	require.NoError(t, err, "failed test %s s", name)
+
+	require.Len(t, po.Results.Environments, 2, "should have a result for each environment")
+	var envNames []string
+	for _, r := range po.Results.Environments {
+		envNames = append(envNames, r.Environment)
+		assert.Equal(t, promote.PromoteStateCreated, r.State, "state of environment %s", r.Environment)
+		assert.NotEmpty(t, r.PullRequestURL, "pull request of environment %s", r.Environment)
+	}
+	assert.ElementsMatch(t, []string{"staging", "production"}, envNames, "environments")
+}
+
 func TestPromoteHelmfileCustomNamespace(t *testing.T) {
 	version := "1.2.3"
 	appName := "myapp"
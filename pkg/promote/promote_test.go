@@ -4,17 +4,49 @@
 package promote_test
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/jenkins-x/go-scm/scm"
+	scmfake "github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
 	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
 	"github.com/stretchr/testify/require"
 
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+	promotefake "github.com/jenkins-x-plugins/jx-promote/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/envctx"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/jxtesthelpers"
 	"github.com/jenkins-x-plugins/jx-promote/pkg/promote"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/rollout"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/telemetry"
 	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	v1fake "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/input/fake"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/activities"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
 )
 
 func fakeSearchForChart(f string) (string, error) {
@@ -194,3 +226,1833 @@ func TestIsLocalChartRepository(t *testing.T) {
 		assert.False(t, actual, "not local repo %s", repo)
 	}
 }
+
+func TestLoadManifest(t *testing.T) {
+	manifest, err := promote.LoadManifest(filepath.Join("test_data", "manifest", "apps.yaml"))
+	require.NoError(t, err, "failed to load manifest")
+
+	po := &promote.Options{
+		ManifestApps:      manifest.Apps,
+		HelmRepositoryURL: "http://default-charts",
+	}
+	apps := po.PromoteApps()
+	require.Len(t, apps, 2, "apps")
+
+	assert.Equal(t, promote.ManifestApp{
+		App:         "cheese",
+		Version:     "1.2.3",
+		ReleaseName: "cheese",
+		HelmRepoURL: "http://default-charts",
+	}, apps[0])
+	assert.Equal(t, promote.ManifestApp{
+		App:         "wine",
+		Version:     "2.0.0",
+		ReleaseName: "my-wine",
+		Alias:       "vino",
+		HelmRepoURL: "https://charts.example.com",
+		Changelog:   "test_data/a_changelog.md",
+	}, apps[1])
+}
+
+func TestWriteResults(t *testing.T) {
+	for _, format := range []string{promote.OutputFormatJSON, promote.OutputFormatYAML} {
+		outFile := filepath.Join(t.TempDir(), "result."+format)
+		po := &promote.Options{
+			Output:     format,
+			OutputFile: outFile,
+			Results: promote.PromoteResults{
+				Environments: []promote.EnvironmentResult{
+					{
+						Environment:       "staging",
+						App:               "myapp",
+						Version:           "1.2.3",
+						PullRequestNumber: 5,
+						PullRequestURL:    "https://github.com/myorg/staging/pull/5",
+						State:             promote.PromoteStateMerged,
+						MergeSHA:          "abc123",
+					},
+				},
+			},
+		}
+		err := po.WriteResults()
+		require.NoError(t, err, "failed to write results as %s", format)
+
+		data, err := os.ReadFile(outFile)
+		require.NoError(t, err, "failed to read results file %s", outFile)
+
+		actual := promote.PromoteResults{}
+		err = yaml.Unmarshal(data, &actual)
+		require.NoError(t, err, "failed to unmarshal results file %s", outFile)
+		assert.Equal(t, po.Results, actual, "results in format %s", format)
+	}
+}
+
+func TestPromoteInEnvDir(t *testing.T) {
+	// lets make sure no cluster is used
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing-kubeconfig"))
+	envDir := t.TempDir()
+
+	po := &promote.Options{
+		Version:           "1.2.3",
+		HelmRepositoryURL: "https://charts.example.com",
+		Environments:      []string{"staging"},
+		EnvDir:            envDir,
+		IgnoreLocalFiles:  true,
+	}
+	po.Application = "myapp"
+	po.BatchMode = true
+	err := po.Run()
+	require.NoError(t, err, "failed to promote into dir %s", envDir)
+	assert.Nil(t, po.KubeClient, "should not have created a kube client")
+	assert.Nil(t, po.JXClient, "should not have created a jx client")
+
+	require.NotNil(t, po.ReleaseInfo, "ReleaseInfo")
+	assert.Nil(t, po.ReleaseInfo.PullRequestInfo, "should not have created a Pull Request")
+
+	helmfile := filepath.Join(envDir, "helmfile.yaml")
+	require.FileExists(t, helmfile)
+	data, err := os.ReadFile(helmfile)
+	require.NoError(t, err, "failed to read %s", helmfile)
+	assert.Contains(t, string(data), "name: myapp")
+	assert.Contains(t, string(data), "version: 1.2.3")
+}
+
+func TestMergeCommitTitle(t *testing.T) {
+	po := &promote.Options{
+		MergeCommitTemplate: "chore: promote {{.App}} {{.Version}} to {{.Environment}} (#{{.PullRequestNumber}})",
+	}
+	po.Application = "myapp"
+	env := &jxcore.EnvironmentConfig{Key: "staging"}
+	releaseInfo := &promote.ReleaseInfo{Version: "1.2.3"}
+	pr := &scm.PullRequest{Number: 7}
+
+	actual, err := po.MergeCommitTitle(env, releaseInfo, pr)
+	require.NoError(t, err, "failed to evaluate merge commit title")
+	assert.Equal(t, "chore: promote myapp 1.2.3 to staging (#7)", actual)
+
+	po.MergeCommitTemplate = ""
+	actual, err = po.MergeCommitTitle(env, releaseInfo, pr)
+	require.NoError(t, err, "failed to evaluate default merge commit title")
+	assert.Equal(t, promote.DefaultMergeCommitTemplate, actual)
+}
+
+func TestEnableNativeAutoMergeGitHub(t *testing.T) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		require.NoError(t, err, "failed to decode GraphQL request")
+		requests = append(requests, body)
+
+		if len(requests) == 1 {
+			fmt.Fprintln(w, `{"data": {"repository": {"pullRequest": {"id": "PR_abc"}}}}`)
+			return
+		}
+		fmt.Fprintln(w, `{"data": {"enablePullRequestAutoMerge": {"clientMutationId": null}}}`)
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err, "failed to create GitHub client")
+
+	po := &promote.Options{
+		MergeMethod: promote.MergeMethodSquash,
+	}
+	po.ScmClient = scmClient
+	releaseInfo := &promote.ReleaseInfo{
+		PullRequestInfo: &scm.PullRequest{
+			Number: 5,
+			Link:   "https://github.com/myorg/environment-staging/pull/5",
+			Base: scm.PullRequestBranch{
+				Repo: scm.Repository{FullName: "myorg/environment-staging"},
+			},
+		},
+	}
+	err = po.EnableNativeAutoMerge(&jxcore.EnvironmentConfig{Key: "staging"}, releaseInfo)
+	require.NoError(t, err, "failed to enable auto merge")
+
+	require.Len(t, requests, 2, "GraphQL requests")
+	assert.Equal(t, map[string]interface{}{"owner": "myorg", "name": "environment-staging", "number": float64(5)}, requests[0]["variables"])
+	assert.Equal(t, map[string]interface{}{"id": "PR_abc", "method": "SQUASH", "headline": promote.DefaultMergeCommitTemplate}, requests[1]["variables"])
+}
+
+func TestValidateNativeAutoMerge(t *testing.T) {
+	testCases := []struct {
+		name              string
+		verifyRollout     bool
+		rollbackOnFailure bool
+		valid             bool
+	}{
+		{name: "native-auto-merge", valid: true},
+		{name: "verify-rollout", verifyRollout: true},
+		{name: "rollback-on-failure", rollbackOnFailure: true},
+	}
+	for _, tc := range testCases {
+		po := &promote.Options{
+			Namespace:         "jx",
+			KubeClient:        kubefake.NewSimpleClientset(),
+			JXClient:          v1fake.NewSimpleClientset(),
+			NativeAutoMerge:   true,
+			VerifyRollout:     tc.verifyRollout,
+			RollbackOnFailure: tc.rollbackOnFailure,
+		}
+		err := po.Validate()
+		if tc.valid {
+			require.NoError(t, err, "%s should be valid", tc.name)
+		} else {
+			require.Error(t, err, "%s should not be valid", tc.name)
+			t.Logf("got expected error: %s", err.Error())
+		}
+	}
+}
+
+func TestValidateWebhookSecret(t *testing.T) {
+	po, _, _ := newTestGitOpsOptions(t)
+	po.Application = "myapp"
+	po.Version = "1.2.3"
+	po.WebhookListen = "127.0.0.1:0"
+	err := po.Run()
+	require.Error(t, err, "should not listen for webhooks without a secret")
+	assert.Contains(t, err.Error(), "--webhook-secret")
+}
+
+func TestRequiredChecksStatus(t *testing.T) {
+	required := []string{"verify", "kubeconform", "security-scan"}
+	testCases := []struct {
+		name     string
+		statuses []*scm.Status
+		state    scm.State
+		desc     string
+	}{
+		{
+			name: "all succeeded ignoring optional failures",
+			statuses: []*scm.Status{
+				{Label: "flaky", State: scm.StateFailure},
+				{Label: "verify", State: scm.StateSuccess},
+				{Label: "kubeconform", State: scm.StateSuccess},
+				{Label: "security-scan", State: scm.StateSuccess},
+			},
+			state: scm.StateSuccess,
+			desc:  "all required checks succeeded: verify, kubeconform, security-scan",
+		},
+		{
+			name: "pending and missing",
+			statuses: []*scm.Status{
+				{Label: "verify", State: scm.StateSuccess},
+				{Label: "kubeconform", State: scm.StatePending},
+			},
+			state: scm.StatePending,
+			desc:  "required checks pending: kubeconform, security-scan (missing)",
+		},
+		{
+			name: "failing uses the latest status",
+			statuses: []*scm.Status{
+				{Label: "verify", State: scm.StateFailure},
+				{Label: "verify", State: scm.StateSuccess},
+				{Label: "kubeconform", State: scm.StateSuccess},
+				{Label: "security-scan", State: scm.StateRunning},
+			},
+			state: scm.StateFailure,
+			desc:  "required checks failing: verify pending: security-scan",
+		},
+	}
+
+	for _, tc := range testCases {
+		actual := promote.RequiredChecksStatus(tc.statuses, required)
+		require.NotNil(t, actual, "for %s", tc.name)
+		assert.Equal(t, tc.state, actual.State, "state for %s", tc.name)
+		assert.Equal(t, tc.desc, actual.Desc, "description for %s", tc.name)
+	}
+}
+
+func TestWaitForRollout(t *testing.T) {
+	release := &rollout.HelmRelease{Name: "myapp", Namespace: "jx-staging", Version: 1}
+	release.Info.Status = rollout.HelmStatusDeployed
+	release.Chart.Metadata.Version = "1.2.3"
+	data, err := rollout.EncodeHelmRelease(release)
+	require.NoError(t, err, "failed to encode release")
+
+	pollDuration := time.Millisecond
+	po := &promote.Options{
+		KubeClient: kubefake.NewSimpleClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "sh.helm.release.v1.myapp.v1",
+				Namespace: "jx-staging",
+				Labels:    map[string]string{"owner": "helm", "name": "myapp", "version": "1"},
+			},
+			Data: map[string][]byte{"release": data},
+		}),
+		PullRequestPollDuration: &pollDuration,
+	}
+	envs := []*jxcore.EnvironmentConfig{{Key: "staging"}}
+	releaseInfo := &promote.ReleaseInfo{
+		Apps: []promote.ManifestApp{{App: "myapp", Version: "1.2.3"}},
+	}
+	err = po.WaitForRollout(envs, releaseInfo, time.Now().Add(time.Second))
+	require.NoError(t, err, "should have rolled out")
+
+	releaseInfo.Apps[0].Version = "2.0.0"
+	err = po.WaitForRollout(envs, releaseInfo, time.Now().Add(10*time.Millisecond))
+	require.Error(t, err, "should have timed out")
+	assert.Equal(t, promote.PromoteStateTimedOut, releaseInfo.State)
+}
+
+func TestRunSmokeCheck(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if calls < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "myapp version %s", r.URL.Query().Get("version"))
+	}))
+	defer server.Close()
+
+	retries := 2
+	check := &v1alpha1.SmokeCheck{
+		Name:          "health",
+		URL:           "{{.URL}}/healthz?version={{.Version}}",
+		BodyRegex:     `version 1\.2\.3`,
+		Retries:       &retries,
+		RetryInterval: "1ms",
+	}
+	templateContext := &promote.SmokeCheckContext{
+		URL:     server.URL,
+		App:     "myapp",
+		Version: "1.2.3",
+	}
+	err := promote.RunSmokeCheck(context.TODO(), server.Client(), check, templateContext)
+	require.NoError(t, err, "smoke check should pass after retrying")
+	assert.Equal(t, 2, calls, "number of requests")
+
+	check.BodyRegex = "version 2.0.0"
+	err = promote.RunSmokeCheck(context.TODO(), server.Client(), check, templateContext)
+	require.Error(t, err, "smoke check should fail with wrong body")
+
+	check.URL = "{{.URL}}/missing"
+	check.BodyRegex = ""
+	check.ExpectedStatus = http.StatusNotFound
+	err = promote.RunSmokeCheck(context.TODO(), server.Client(), check, templateContext)
+	require.NoError(t, err, "smoke check should pass with expected status")
+}
+
+func TestRollbackApps(t *testing.T) {
+	devEnvContext := createTestStagingRepository(t, "1.0.0", "")
+	env := &devEnvContext.Requirements.Environments[0]
+	stagingDir := env.GitURL
+
+	po := &promote.Options{
+		Version:           "1.2.3",
+		HelmRepositoryURL: "https://charts.example.com",
+		EnvDir:            stagingDir,
+		EnvDirCommit:      true,
+	}
+	po.Application = "myapp"
+	po.DevEnvContext = devEnvContext
+	releaseInfo := &promote.ReleaseInfo{Version: po.Version, Apps: po.PromoteApps()}
+	err := po.PromoteViaPullRequest([]*jxcore.EnvironmentConfig{env}, releaseInfo, false)
+	require.NoError(t, err, "failed to promote into staging dir %s", stagingDir)
+
+	_, err = po.RollbackApps(env, releaseInfo)
+	require.Error(t, err, "should not rollback without a merge commit")
+
+	sha, err := cli.NewCLIClient("", cmdrunner.QuietCommandRunner).Command(stagingDir, "rev-parse", "HEAD")
+	require.NoError(t, err, "failed to get the sha in %s", stagingDir)
+	releaseInfo.MergeSHA = strings.TrimSpace(sha)
+
+	// lets make sure the previous versions are read from git rather than the options which promoted the release
+	po = &promote.Options{}
+	po.DevEnvContext = devEnvContext
+	apps, err := po.RollbackApps(env, releaseInfo)
+	require.NoError(t, err, "failed to find the rollback apps")
+	require.Len(t, apps, 1, "rollback apps")
+	assert.Equal(t, "myapp", apps[0].App)
+	assert.Equal(t, "1.0.0", apps[0].Version)
+}
+
+func TestCheckFreeze(t *testing.T) {
+	envs := []*jxcore.EnvironmentConfig{{Key: "production"}}
+	now, err := time.Parse(time.RFC3339, "2024-12-24T10:00:00Z")
+	require.NoError(t, err)
+
+	po := &promote.Options{
+		DevPromoteConfig: &v1alpha1.Promote{
+			Spec: v1alpha1.PromoteSpec{
+				Environments: []v1alpha1.PromoteEnvironment{
+					{
+						Name: "production",
+						Freezes: []v1alpha1.FreezePeriod{
+							{
+								Name:  "holidays",
+								Start: "2024-12-20T17:00:00Z",
+								End:   "2025-01-02T09:00:00Z",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	_, err = po.CheckFreeze(envs, now)
+	require.Error(t, err, "should not promote during a freeze")
+
+	po.OverrideFreeze = "urgent security fix"
+	draft, err := po.CheckFreeze(envs, now)
+	require.NoError(t, err, "should promote when overriding the freeze")
+	assert.False(t, draft, "should not be a draft when overriding")
+
+	po.OverrideFreeze = ""
+	po.DevPromoteConfig.Spec.Environments[0].FreezeAction = "draft"
+	draft, err = po.CheckFreeze(envs, now)
+	require.NoError(t, err, "should promote as a draft")
+	assert.True(t, draft, "should be a draft during a freeze")
+
+	draft, err = po.CheckFreeze([]*jxcore.EnvironmentConfig{{Key: "staging"}}, now)
+	require.NoError(t, err, "staging has no freeze")
+	assert.False(t, draft, "staging should not be a draft")
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	envs := []*jxcore.EnvironmentConfig{{Key: "staging"}, {Key: "production"}}
+	releaseInfo := &promote.ReleaseInfo{
+		Version: "1.2.3",
+		Apps:    []promote.ManifestApp{{App: "myapp", Version: "1.2.3"}},
+	}
+
+	po := &promote.Options{
+		Author:           "jstrachan",
+		IgnoreLocalFiles: true,
+		DevPromoteConfig: &v1alpha1.Promote{
+			Spec: v1alpha1.PromoteSpec{
+				Policies: []v1alpha1.PromotePolicy{
+					{
+						Name:         "security-scan",
+						Expression:   `"security-scan" in labels && labels["security-scan"] == "passed"`,
+						Environments: []string{"production"},
+					},
+					{
+						Name:       "trusted-authors",
+						Expression: `author == "jstrachan"`,
+						Action:     "require-manual",
+					},
+				},
+			},
+		},
+	}
+	po.Application = "myapp"
+
+	_, err := po.EvaluatePolicies(envs, releaseInfo, time.Now())
+	require.Error(t, err, "should deny production without a security scan")
+
+	po.PolicyLabels = map[string]string{"security-scan": "passed"}
+	draft, err := po.EvaluatePolicies(envs, releaseInfo, time.Now())
+	require.NoError(t, err, "should allow production with a security scan")
+	assert.False(t, draft, "should not be a draft")
+
+	po.Author = "someone-else"
+	draft, err = po.EvaluatePolicies(envs, releaseInfo, time.Now())
+	require.NoError(t, err, "should allow a manual promotion")
+	assert.True(t, draft, "should be a draft for untrusted authors")
+
+	decisions := releaseInfo.PolicyDecisions["production"]
+	require.Len(t, decisions, 2, "production policy decisions")
+	assert.Equal(t, "allow", decisions[0].Result, "security-scan decision")
+	assert.Equal(t, "require-manual", decisions[1].Result, "trusted-authors decision")
+
+	// the labels of the Release resource cannot be overridden by --policy-label
+	po.Author = "jstrachan"
+	po.Namespace = "jx"
+	po.JXClient = v1fake.NewSimpleClientset(&v1.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myapp-1.2.3",
+			Namespace: "jx",
+			Labels:    map[string]string{"security-scan": "failed"},
+		},
+	})
+	_, err = po.EvaluatePolicies(envs, releaseInfo, time.Now())
+	require.Error(t, err, "should deny production when the Release failed the security scan")
+
+	// each app in a manifest is evaluated
+	po.JXClient = v1fake.NewSimpleClientset()
+	po.Application = ""
+	releaseInfo.Apps = append(releaseInfo.Apps, promote.ManifestApp{App: "another", Version: "2.0.0"})
+	_, err = po.EvaluatePolicies(envs, releaseInfo, time.Now())
+	require.NoError(t, err, "should allow the apps to production")
+	decisions = releaseInfo.PolicyDecisions["production"]
+	require.Len(t, decisions, 4, "production policy decisions")
+	assert.Equal(t, "another", decisions[3].App, "app of the last decision")
+
+	// the author of the latest commit cannot be overridden by --author
+	t.Setenv("GIT_AUTHOR_NAME", "someone")
+	t.Setenv("GIT_AUTHOR_EMAIL", "someone@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "someone")
+	t.Setenv("GIT_COMMITTER_EMAIL", "someone@example.com")
+	po.Dir = t.TempDir()
+	po.IgnoreLocalFiles = false
+	gitter := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+	_, err = gitter.Command(po.Dir, "init")
+	require.NoError(t, err, "failed to init git repository in %s", po.Dir)
+	_, err = gitter.Command(po.Dir, "commit", "--allow-empty", "-m", "a change")
+	require.NoError(t, err, "failed to commit in %s", po.Dir)
+	draft, err = po.EvaluatePolicies(envs, releaseInfo, time.Now())
+	require.NoError(t, err, "should allow a manual promotion")
+	assert.True(t, draft, "should be a draft for the author of the latest commit")
+}
+
+func TestCheckUpstreams(t *testing.T) {
+	devEnvContext := createTestStagingRepository(t, "1.2.3", "")
+
+	po := &promote.Options{
+		DevPromoteConfig: &v1alpha1.Promote{
+			Spec: v1alpha1.PromoteSpec{
+				Environments: []v1alpha1.PromoteEnvironment{
+					{
+						Name:      "production",
+						Upstreams: []string{"staging"},
+					},
+				},
+			},
+		},
+	}
+	po.Application = "myapp"
+	po.DevEnvContext = devEnvContext
+	envs := []*jxcore.EnvironmentConfig{&devEnvContext.Requirements.Environments[1]}
+
+	testCases := []struct {
+		version string
+		valid   bool
+	}{
+		{version: "1.2.3", valid: true},
+		{version: "1.3.0"},
+		{version: "1.0.0"},
+	}
+	for _, tc := range testCases {
+		po.Version = tc.version
+		releaseInfo := &promote.ReleaseInfo{Version: tc.version, Apps: po.PromoteApps()}
+		err := po.CheckUpstreams(envs, releaseInfo)
+		if tc.valid {
+			require.NoError(t, err, "should promote version %s in staging", tc.version)
+		} else {
+			require.Error(t, err, "should not promote version %s which is not in staging", tc.version)
+			t.Logf("got expected error: %s", err.Error())
+		}
+	}
+}
+
+func TestResolveFromEnvironment(t *testing.T) {
+	devEnvContext := createTestStagingRepository(t, "1.2.3", "my-release")
+
+	po := &promote.Options{
+		FromEnvironment: "staging",
+		Environments:    []string{"production"},
+	}
+	po.Application = "myapp"
+	po.DevEnvContext = devEnvContext
+	err := po.ResolveFromEnvironment()
+	require.NoError(t, err, "failed to resolve from staging")
+	assert.Equal(t, "1.2.3", po.Version, "version")
+	assert.Equal(t, "https://charts.example.com", po.HelmRepositoryURL, "helm repository URL")
+	assert.Equal(t, "my-release", po.ReleaseName, "release name")
+
+	po = &promote.Options{FromEnvironment: "staging"}
+	po.Application = "another"
+	po.DevEnvContext = devEnvContext
+	err = po.ResolveFromEnvironment()
+	require.Error(t, err, "should fail for an app not in staging")
+}
+
+// createTestStagingRepository creates a git repository for the staging environment with myapp promoted into it and
+// returns the EnvironmentContext with staging and production environments
+func createTestStagingRepository(t *testing.T, version, releaseName string) envctx.EnvironmentContext {
+	stagingDir := t.TempDir()
+	_, err := cli.NewCLIClient("", nil).Command(stagingDir, "init")
+	require.NoError(t, err, "failed to init git repository in %s", stagingDir)
+
+	devEnvContext := *jxtesthelpers.CreateTestDevEnvironmentContext(t, "jx")
+	devEnvContext.Requirements.Environments = []jxcore.EnvironmentConfig{
+		{
+			Key:       "staging",
+			Namespace: "jx-staging",
+			GitURL:    stagingDir,
+		},
+		{
+			Key:       "production",
+			Namespace: "jx-production",
+		},
+	}
+	po := &promote.Options{
+		Version:           version,
+		HelmRepositoryURL: "https://charts.example.com",
+		ReleaseName:       releaseName,
+		EnvDir:            stagingDir,
+		EnvDirCommit:      true,
+	}
+	po.Application = "myapp"
+	po.DevEnvContext = devEnvContext
+	releaseInfo := &promote.ReleaseInfo{Version: version, Apps: po.PromoteApps()}
+	err = po.PromoteViaPullRequest([]*jxcore.EnvironmentConfig{&devEnvContext.Requirements.Environments[0]}, releaseInfo, false)
+	require.NoError(t, err, "failed to promote into staging dir %s", stagingDir)
+	return devEnvContext
+}
+
+const (
+	// testGitURLPrefix the prefix of the environment git URLs cloned from local bare repositories by newTestGitOpsOptions
+	testGitURLPrefix = "https://github.com/myorg/"
+
+	// testStagingGitURL the git URL of the staging environment used by newTestGitOpsOptions
+	testStagingGitURL = testGitURLPrefix + "environment-staging.git"
+)
+
+// newTestGitOpsOptions returns the options to promote to a staging environment whose git repository is cloned from a
+// local bare repository with the Pull Requests created in the returned fake SCM
+func newTestGitOpsOptions(t *testing.T) (*promote.Options, *scmfake.Data, string) {
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	tmpDir := t.TempDir()
+	remoteDir := createTestEnvironmentRepository(t, tmpDir, "staging", nil)
+
+	// lets clone the local repositories rather than the environment git URLs
+	runner := func(c *cmdrunner.Command) (string, error) {
+		for i, arg := range c.Args {
+			if strings.HasPrefix(arg, testGitURLPrefix) {
+				c.Args[i] = filepath.Join(tmpDir, strings.TrimPrefix(arg, testGitURLPrefix))
+			}
+		}
+		return cmdrunner.QuietCommandRunner(c)
+	}
+
+	devEnvContext := *jxtesthelpers.CreateTestDevEnvironmentContext(t, "jx")
+	devEnvContext.Requirements.Environments = []jxcore.EnvironmentConfig{
+		{
+			Key:               "staging",
+			Namespace:         "jx-staging",
+			GitURL:            testStagingGitURL,
+			PromotionStrategy: v1.PromotionStrategyTypeAutomatic,
+		},
+	}
+	scmClient, scmData := scmfake.NewDefault()
+
+	po := &promote.Options{
+		Namespace:         "jx",
+		KubeClient:        kubefake.NewSimpleClientset(),
+		JXClient:          v1fake.NewSimpleClientset(),
+		HelmRepositoryURL: "https://charts.example.com",
+		Environments:      []string{"staging"},
+		NoPoll:            true,
+		IgnoreLocalFiles:  true,
+		Pipeline:          "myorg/myapp/main",
+		Build:             "1",
+		DevPromoteConfig:  &v1alpha1.Promote{},
+		Input:             &fake.FakeInput{},
+	}
+	po.DevEnvContext = devEnvContext
+	po.BatchMode = true
+	po.CommandRunner = runner
+	po.Gitter = cli.NewCLIClient("", runner)
+	po.GitKind = "fake"
+	po.ScmClientFactory.GitServerURL = "https://github.com"
+	po.ScmClientFactory.ScmClient = scmClient
+	return po, scmData, remoteDir
+}
+
+// createTestEnvironmentRepository creates the bare repository in the dir which newTestGitOpsOptions clones the git URL
+// of the environment from with an initial commit of the given files
+func createTestEnvironmentRepository(t *testing.T, tmpDir, key string, files map[string]string) string {
+	remoteDir := filepath.Join(tmpDir, "environment-"+key+".git")
+	initDir := filepath.Join(tmpDir, "init-"+key)
+	gitter := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+	for _, args := range [][]string{
+		{"init", "--bare", "--initial-branch=main", remoteDir},
+		{"init", "--initial-branch=main", initDir},
+	} {
+		_, err := gitter.Command(tmpDir, args...)
+		require.NoError(t, err, "failed to run git %v", args)
+	}
+
+	for name, text := range files {
+		path := filepath.Join(initDir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		require.NoError(t, err, "failed to create dir for %s", path)
+		err = os.WriteFile(path, []byte(text), 0o600)
+		require.NoError(t, err, "failed to write %s", path)
+	}
+	for _, args := range [][]string{
+		{"-C", initDir, "add", "-A"},
+		{"-C", initDir, "commit", "--allow-empty", "-m", "initial commit"},
+		{"-C", initDir, "push", remoteDir, "main"},
+	} {
+		_, err := gitter.Command(tmpDir, args...)
+		require.NoError(t, err, "failed to run git %v", args)
+	}
+	return remoteDir
+}
+
+func TestPromoteParallel(t *testing.T) {
+	po, scmData, remoteDir := newTestGitOpsOptions(t)
+	tmpDir := filepath.Dir(remoteDir)
+	createTestEnvironmentRepository(t, tmpDir, "test", map[string]string{
+		".jx/promote.yaml": `apiVersion: promote.jenkins-x.io/v1alpha1
+kind: Promote
+spec:
+  environments:
+  - name: test
+    directPush: true
+`,
+	})
+	createTestEnvironmentRepository(t, tmpDir, "production", nil)
+	createTestEnvironmentRepository(t, tmpDir, "qa", nil)
+
+	po.DevEnvContext.Requirements.Environments = []jxcore.EnvironmentConfig{
+		{
+			Key:               "test",
+			Namespace:         "jx-test",
+			GitURL:            testGitURLPrefix + "environment-test.git",
+			PromotionStrategy: v1.PromotionStrategyTypeAutomatic,
+		},
+		{
+			Key:               "production",
+			Namespace:         "jx-production",
+			GitURL:            testGitURLPrefix + "environment-production.git",
+			PromotionStrategy: v1.PromotionStrategyTypeManual,
+		},
+		{
+			Key:               "qa",
+			Namespace:         "jx-qa",
+			GitURL:            testGitURLPrefix + "environment-qa.git",
+			PromotionStrategy: v1.PromotionStrategyTypeManual,
+		},
+	}
+	po.DevPromoteConfig.Spec.Environments = []v1alpha1.PromoteEnvironment{
+		{
+			Name:      "production",
+			Upstreams: []string{"test"},
+		},
+	}
+	po.Environments = []string{"test", "production", "qa"}
+	po.Parallel = true
+
+	// the fake SCM is not safe for concurrent use unlike the real clients
+	po.ScmClientFactory.ScmClient.PullRequests = &lockingPullRequestService{PullRequestService: po.ScmClientFactory.ScmClient.PullRequests}
+	po.Application = "myapp"
+	po.Version = "1.2.3"
+
+	err := po.Run()
+	require.NoError(t, err, "failed to promote in parallel")
+
+	// the test environment is pushed directly so there are only Pull Requests for the manual environments
+	var titles []string
+	for _, pr := range scmData.PullRequests {
+		titles = append(titles, pr.Base.Repo.Name+": "+pr.Title)
+	}
+	sort.Strings(titles)
+	assert.Equal(t, []string{
+		"environment-production: chore: promote myapp to version 1.2.3",
+		"environment-qa: chore: promote myapp to version 1.2.3",
+	}, titles, "pull requests")
+
+	var envs []string
+	for _, r := range po.Results.Environments {
+		envs = append(envs, r.Environment)
+	}
+	sort.Strings(envs)
+	assert.Equal(t, []string{"production", "qa", "test"}, envs, "promoted environments")
+
+	// the groups update the same PipelineActivity so lets check no step was lost
+	activity, err := po.JXClient.JenkinsV1().PipelineActivities("jx").Get(context.TODO(), po.ReleaseInfo.PipelineActivity, metav1.GetOptions{})
+	require.NoError(t, err, "failed to get PipelineActivity %s", po.ReleaseInfo.PipelineActivity)
+	var steps []string
+	for _, step := range activity.Spec.Steps {
+		if step.Promote != nil {
+			steps = append(steps, step.Promote.Environment)
+		}
+	}
+	sort.Strings(steps)
+	assert.Equal(t, []string{"production", "qa", "test"}, steps, "PipelineActivity promote steps")
+}
+
+func TestPromoteHoldsLockWhileWaiting(t *testing.T) {
+	po, scmData, _ := newTestGitOpsOptions(t)
+	po.Application = "myapp"
+	po.Version = "1.2.3"
+	po.Lock = true
+	po.NoPoll = false
+	po.NoWaitAfterMerge = true
+	po.Timeout = "1m"
+	po.PullRequestPollTime = "1ms"
+
+	leases := -1
+	waitHandler := func(w *promote.PullRequestWaiter, _ *promote.PullRequestPoll) (bool, error) {
+		list, err := w.KubeClient.CoordinationV1().Leases("jx").List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		leases = len(list.Items)
+		return true, nil
+	}
+	po.PullRequestHandlers = map[promote.PullRequestState]promote.PullRequestHandler{}
+	for _, state := range []promote.PullRequestState{
+		promote.PullRequestStatePending,
+		promote.PullRequestStateSucceeded,
+		promote.PullRequestStateFailed,
+		promote.PullRequestStateConflict,
+	} {
+		po.PullRequestHandlers[state] = waitHandler
+	}
+
+	err := po.Run()
+	require.NoError(t, err, "failed to promote")
+	require.Len(t, scmData.PullRequests, 1, "pull requests")
+	assert.Equal(t, 1, leases, "the lock should be held while waiting for the Pull Request to merge")
+
+	list, err := po.KubeClient.CoordinationV1().Leases("jx").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err, "failed to list Leases")
+	assert.Empty(t, list.Items, "the lock should be released once the wait ends")
+}
+
+func TestPromoteAbortsWhenLockLost(t *testing.T) {
+	po, _, _ := newTestGitOpsOptions(t)
+	po.Application = "myapp"
+	po.Version = "1.2.3"
+	po.Lock = true
+	po.LockTTL = "30ms"
+	po.NoPoll = false
+	po.Timeout = "1m"
+	po.PullRequestPollTime = "1ms"
+
+	stolen := false
+	po.PullRequestHandlers = map[promote.PullRequestState]promote.PullRequestHandler{
+		promote.PullRequestStatePending: func(w *promote.PullRequestWaiter, _ *promote.PullRequestPoll) (bool, error) {
+			if stolen {
+				return false, nil
+			}
+			// lets simulate another promotion taking over the lock while waiting
+			list, err := w.KubeClient.CoordinationV1().Leases("jx").List(context.TODO(), metav1.ListOptions{})
+			if err != nil || len(list.Items) != 1 {
+				return false, fmt.Errorf("expected one Lease: %v", err)
+			}
+			l := &list.Items[0]
+			holder := "other"
+			ttl := int32(60)
+			renewTime := metav1.NewMicroTime(time.Now())
+			l.Spec.HolderIdentity = &holder
+			l.Spec.LeaseDurationSeconds = &ttl
+			l.Spec.RenewTime = &renewTime
+			_, err = w.KubeClient.CoordinationV1().Leases("jx").Update(context.TODO(), l, metav1.UpdateOptions{})
+			stolen = true
+			time.Sleep(100 * time.Millisecond)
+			return false, err
+		},
+	}
+
+	err := po.Run()
+	require.Error(t, err, "should abort the promotion when the lock is lost")
+	assert.Contains(t, err.Error(), "lost the promotion lock")
+}
+
+func TestPromoteRecordsFailedResults(t *testing.T) {
+	po, scmData, remoteDir := newTestGitOpsOptions(t)
+	createTestEnvironmentRepository(t, filepath.Dir(remoteDir), "production", nil)
+	po.DevEnvContext.Requirements.Environments = append(po.DevEnvContext.Requirements.Environments, jxcore.EnvironmentConfig{
+		Key:               "production",
+		Namespace:         "jx-production",
+		GitURL:            testGitURLPrefix + "environment-production.git",
+		PromotionStrategy: v1.PromotionStrategyTypeManual,
+	})
+	po.DevPromoteConfig.Spec.Policies = []v1alpha1.PromotePolicy{
+		{
+			Name:         "no-myapp",
+			Expression:   `app != "myapp"`,
+			Environments: []string{"staging"},
+		},
+	}
+	po.Environments = []string{"staging", "production"}
+	po.Application = "myapp"
+	po.Version = "1.2.3"
+
+	err := po.Run()
+	require.Error(t, err, "should be denied by policy")
+	assert.Empty(t, scmData.PullRequests, "pull requests")
+
+	results := po.Results.Environments
+	require.Len(t, results, 2, "results")
+	assert.Equal(t, "staging", results[0].Environment)
+	assert.Equal(t, promote.PromoteStateFailed, results[0].State, "staging state")
+	require.Len(t, results[0].Policies, 1, "staging policies")
+	assert.Equal(t, "no-myapp", results[0].Policies[0].Policy)
+	assert.Equal(t, "deny", results[0].Policies[0].Result)
+	assert.Equal(t, "production", results[1].Environment)
+	assert.Equal(t, promote.PromoteStateSkipped, results[1].State, "production state")
+	assert.Equal(t, "1.2.3", results[1].Version, "production version")
+}
+
+// lockingPullRequestService serialises the calls to the fake Pull Request service used when promoting in parallel
+type lockingPullRequestService struct {
+	scm.PullRequestService
+	mu sync.Mutex
+}
+
+func (s *lockingPullRequestService) Find(ctx context.Context, repo string, number int) (*scm.PullRequest, *scm.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.PullRequestService.Find(ctx, repo, number)
+}
+
+func (s *lockingPullRequestService) List(ctx context.Context, repo string, opts *scm.PullRequestListOptions) ([]*scm.PullRequest, *scm.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.PullRequestService.List(ctx, repo, opts)
+}
+
+func (s *lockingPullRequestService) Create(ctx context.Context, repo string, input *scm.PullRequestInput) (*scm.PullRequest, *scm.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.PullRequestService.Create(ctx, repo, input)
+}
+
+func (s *lockingPullRequestService) Update(ctx context.Context, repo string, number int, input *scm.PullRequestInput) (*scm.PullRequest, *scm.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.PullRequestService.Update(ctx, repo, number, input)
+}
+
+func (s *lockingPullRequestService) AddLabel(ctx context.Context, repo string, number int, label string) (*scm.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.PullRequestService.AddLabel(ctx, repo, number, label)
+}
+
+func TestPromoteManifest(t *testing.T) {
+	po, scmData, _ := newTestGitOpsOptions(t)
+	po.FromManifest = filepath.Join(t.TempDir(), "manifest.yaml")
+	manifest := &promote.Manifest{
+		Apps: []promote.ManifestApp{
+			{
+				App:     "myapp",
+				Version: "1.2.3",
+			},
+			{
+				App:         "another",
+				Version:     "2.0.0",
+				ReleaseName: "another-release",
+			},
+		},
+	}
+	data, err := yaml.Marshal(manifest)
+	require.NoError(t, err, "failed to marshal manifest")
+	err = os.WriteFile(po.FromManifest, data, 0o600)
+	require.NoError(t, err, "failed to write manifest %s", po.FromManifest)
+
+	err = po.Run()
+	require.NoError(t, err, "failed to promote the manifest")
+
+	require.Len(t, scmData.PullRequestsCreated, 1, "should create a single Pull Request for both apps")
+	pr := scmData.PullRequestsCreated[1]
+	require.NotNil(t, pr, "Pull Request")
+	assert.Equal(t, "chore: promote myapp, another", pr.Title, "Pull Request title")
+	assert.Contains(t, pr.Body, "* myapp to version 1.2.3")
+	assert.Contains(t, pr.Body, "* another to version 2.0.0")
+
+	releaseInfo := po.ReleaseInfo
+	require.NotNil(t, releaseInfo, "ReleaseInfo")
+	assert.Equal(t, "myapp, another", releaseInfo.FullAppName, "FullAppName")
+	assert.Equal(t, "myapp, another-release", releaseInfo.ReleaseName, "ReleaseName")
+	assert.Equal(t, "1.2.3, 2.0.0", releaseInfo.Version, "Version")
+
+	require.Len(t, po.Results.Environments, 1, "results")
+	assert.Equal(t, "myapp, another", po.Results.Environments[0].App, "result app")
+	assert.Equal(t, "1.2.3, 2.0.0", po.Results.Environments[0].Version, "result version")
+	assert.Len(t, po.Results.Environments[0].Apps, 2, "result apps")
+
+	activity, err := po.JXClient.JenkinsV1().PipelineActivities("jx").Get(context.TODO(), releaseInfo.PipelineActivity, metav1.GetOptions{})
+	require.NoError(t, err, "failed to get PipelineActivity %s", releaseInfo.PipelineActivity)
+	assert.Empty(t, activity.Spec.Version, "PipelineActivity version of a manifest")
+}
+
+func TestCommentOnIssuesManifest(t *testing.T) {
+	po, scmData, _ := newTestGitOpsOptions(t)
+	po.ScmClient = po.ScmClientFactory.ScmClient
+	po.ManifestApps = []promote.ManifestApp{
+		{
+			App:     "myapp",
+			Version: "1.2.3",
+		},
+		{
+			App:     "another",
+			Version: "2.0.0",
+		},
+	}
+	ctx := context.TODO()
+	ns := "jx-staging"
+	for i, app := range po.ManifestApps {
+		_, err := po.KubeClient.ExtensionsV1beta1().Ingresses(ns).Create(ctx, &extv1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: app.App},
+			Spec: extv1beta1.IngressSpec{
+				Rules: []extv1beta1.IngressRule{{Host: app.App + ".example.com"}},
+			},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err, "failed to create Ingress for %s", app.App)
+
+		_, err = po.JXClient.JenkinsV1().Releases(ns).Create(ctx, &v1.Release{
+			ObjectMeta: metav1.ObjectMeta{Name: app.App + "-" + app.Version},
+			Spec: v1.ReleaseSpec{
+				GitOwner:      "myorg",
+				GitRepository: app.App,
+				Issues: []v1.IssueSummary{
+					{
+						ID:    fmt.Sprintf("%d", i+1),
+						URL:   fmt.Sprintf("https://github.com/myorg/%s/issues/%d", app.App, i+1),
+						State: "closed",
+					},
+				},
+			},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err, "failed to create Release for %s", app.App)
+	}
+
+	env := &po.DevEnvContext.Requirements.Environments[0]
+	promoteKey := po.CreatePromoteKey(env)
+	err := po.CommentOnIssues(env, promoteKey)
+	require.NoError(t, err, "failed to comment on issues")
+
+	require.Len(t, scmData.IssueCommentsAdded, 2, "comments")
+	assert.Contains(t, scmData.IssueCommentsAdded[0], "myorg/myapp#1:", "comment on myapp issue")
+	assert.Contains(t, scmData.IssueCommentsAdded[0], "version 1.2.3", "comment on myapp issue")
+	assert.Contains(t, scmData.IssueCommentsAdded[1], "myorg/another#2:", "comment on another issue")
+	assert.Contains(t, scmData.IssueCommentsAdded[1], "version 2.0.0", "comment on another issue")
+	assert.Equal(t, "myapp.example.com", promoteKey.ApplicationURL, "ApplicationURL")
+}
+
+func TestSync(t *testing.T) {
+	devEnvContext := createTestStagingRepository(t, "1.2.3", "")
+	stagingEnv := &devEnvContext.Requirements.Environments[0]
+	productionEnv := &devEnvContext.Requirements.Environments[1]
+
+	promoteInto := func(env *jxcore.EnvironmentConfig, dir, app, version string, commit bool) {
+		po := &promote.Options{
+			Version:           version,
+			HelmRepositoryURL: "https://charts.example.com",
+			EnvDir:            dir,
+			EnvDirCommit:      commit,
+		}
+		po.Application = app
+		po.DevEnvContext = devEnvContext
+		releaseInfo := &promote.ReleaseInfo{Version: version, Apps: po.PromoteApps()}
+		err := po.PromoteViaPullRequest([]*jxcore.EnvironmentConfig{env}, releaseInfo, false)
+		require.NoError(t, err, "failed to promote %s into dir %s", app, dir)
+	}
+	promoteInto(stagingEnv, stagingEnv.GitURL, "another", "2.0.0", true)
+	promoteInto(stagingEnv, stagingEnv.GitURL, "unchanged", "3.0.0", true)
+
+	productionDir := t.TempDir()
+	promoteInto(productionEnv, productionDir, "myapp", "1.0.0", false)
+	promoteInto(productionEnv, productionDir, "unchanged", "3.0.0", false)
+
+	so := &promote.SyncOptions{
+		From: "staging",
+		To:   "production",
+	}
+	so.DevEnvContext = devEnvContext
+	so.EnvDir = productionDir
+	err := so.Sync()
+	require.NoError(t, err, "failed to sync")
+
+	require.Len(t, so.SyncedApps, 2, "synced apps")
+	assert.Equal(t, "another", so.SyncedApps[0].App)
+	assert.Equal(t, "2.0.0", so.SyncedApps[0].Version)
+	assert.Equal(t, "", so.SyncedApps[0].PreviousVersion)
+	assert.Equal(t, "myapp", so.SyncedApps[1].App)
+	assert.Equal(t, "1.2.3", so.SyncedApps[1].Version)
+	assert.Equal(t, "1.0.0", so.SyncedApps[1].PreviousVersion)
+	assert.Contains(t, so.CommitMessage, "| myapp | 1.0.0 | 1.2.3 |")
+
+	data, err := os.ReadFile(filepath.Join(productionDir, "helmfile.yaml"))
+	require.NoError(t, err, "failed to read production helmfile")
+	assert.Contains(t, string(data), "version: 1.2.3")
+	assert.Contains(t, string(data), "version: 2.0.0")
+	assert.NotContains(t, string(data), "version: 1.0.0")
+
+	so.Apps = []string{"missing"}
+	err = so.Sync()
+	require.Error(t, err, "should fail for an app not in staging")
+}
+
+func TestSyncGates(t *testing.T) {
+	devEnvContext := createTestStagingRepository(t, "1.2.3", "")
+	stagingEnv := &devEnvContext.Requirements.Environments[0]
+	productionEnv := &devEnvContext.Requirements.Environments[1]
+
+	promoteInto := func(env *jxcore.EnvironmentConfig, dir, app, version string) {
+		po := &promote.Options{
+			Version:           version,
+			HelmRepositoryURL: "https://charts.example.com",
+			EnvDir:            dir,
+			EnvDirCommit:      true,
+		}
+		po.Application = app
+		po.DevEnvContext = devEnvContext
+		releaseInfo := &promote.ReleaseInfo{Version: version, Apps: po.PromoteApps()}
+		err := po.PromoteViaPullRequest([]*jxcore.EnvironmentConfig{env}, releaseInfo, false)
+		require.NoError(t, err, "failed to promote %s into dir %s", app, dir)
+	}
+	promoteInto(stagingEnv, stagingEnv.GitURL, "unchanged", "3.0.0")
+
+	productionEnv.GitURL = t.TempDir()
+	_, err := cli.NewCLIClient("", cmdrunner.QuietCommandRunner).Command(productionEnv.GitURL, "init")
+	require.NoError(t, err, "failed to init git repository in %s", productionEnv.GitURL)
+	promoteInto(productionEnv, productionEnv.GitURL, "myapp", "1.0.0")
+	promoteInto(productionEnv, productionEnv.GitURL, "unchanged", "3.0.0")
+
+	testCases := []struct {
+		name   string
+		config v1alpha1.PromoteSpec
+	}{
+		{
+			name: "freeze",
+			config: v1alpha1.PromoteSpec{
+				Environments: []v1alpha1.PromoteEnvironment{
+					{
+						Name: "production",
+						Freezes: []v1alpha1.FreezePeriod{
+							{
+								Name:  "forever",
+								Start: "2000-01-01T00:00:00Z",
+								End:   "2100-01-01T00:00:00Z",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "policy",
+			config: v1alpha1.PromoteSpec{
+				Policies: []v1alpha1.PromotePolicy{
+					{
+						Name:       "no-myapp",
+						Expression: `app != "myapp"`,
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		so := &promote.SyncOptions{
+			From: "staging",
+			To:   "production",
+		}
+		so.DevEnvContext = devEnvContext
+		so.DevPromoteConfig = &v1alpha1.Promote{Spec: tc.config}
+		so.IgnoreLocalFiles = true
+		err = so.Sync()
+		require.Error(t, err, "%s should deny the sync", tc.name)
+		t.Logf("got expected error: %s", err.Error())
+		assert.Empty(t, so.SyncedApps, "%s should not sync any apps", tc.name)
+	}
+}
+
+func TestPromoteTracesRules(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	createTestStagingRepository(t, "1.2.3", "")
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1, "spans")
+	span := spans[0]
+	assert.Equal(t, "rule", span.Name())
+	assert.ElementsMatch(t, telemetry.PromoteAttributes("staging", "myapp", "1.2.3"), span.Attributes())
+}
+
+func TestResumePromotion(t *testing.T) {
+	testCases := []struct {
+		name           string
+		version        string
+		promoteStep    *v1.PromoteActivityStep
+		requiredChecks []string
+		expectResumed  bool
+		expectState    promote.PromoteState
+		expectPR       int
+	}{
+		{
+			name:    "waiting",
+			version: "1.2.3",
+			promoteStep: &v1.PromoteActivityStep{
+				PullRequest: &v1.PromotePullRequestStep{
+					CoreActivityStep: v1.CoreActivityStep{Status: v1.ActivityStatusTypeRunning},
+					PullRequestURL:   "https://github.com/myorg/environment-staging/pull/5",
+				},
+			},
+			expectResumed: true,
+			expectState:   promote.PromoteStateCreated,
+			expectPR:      5,
+		},
+		{
+			name:    "required-checks",
+			version: "1.2.3",
+			promoteStep: &v1.PromoteActivityStep{
+				PullRequest: &v1.PromotePullRequestStep{
+					CoreActivityStep: v1.CoreActivityStep{Status: v1.ActivityStatusTypeRunning},
+					PullRequestURL:   "https://github.com/myorg/environment-staging/pull/5",
+				},
+			},
+			requiredChecks: []string{"ci/test"},
+			expectResumed:  true,
+			expectState:    promote.PromoteStateCreated,
+			expectPR:       5,
+		},
+		{
+			name:    "merged",
+			version: "1.2.3",
+			promoteStep: &v1.PromoteActivityStep{
+				PullRequest: &v1.PromotePullRequestStep{
+					CoreActivityStep: v1.CoreActivityStep{Status: v1.ActivityStatusTypeSucceeded},
+					MergeCommitSHA:   "abc123",
+				},
+				Update: &v1.PromoteUpdateStep{
+					CoreActivityStep: v1.CoreActivityStep{Status: v1.ActivityStatusTypeRunning},
+				},
+			},
+			expectResumed: true,
+			expectState:   promote.PromoteStateMerged,
+		},
+		{
+			name:    "completed",
+			version: "1.2.3",
+			promoteStep: &v1.PromoteActivityStep{
+				PullRequest: &v1.PromotePullRequestStep{
+					CoreActivityStep: v1.CoreActivityStep{Status: v1.ActivityStatusTypeSucceeded},
+					MergeCommitSHA:   "abc123",
+				},
+				Update: &v1.PromoteUpdateStep{
+					CoreActivityStep: v1.CoreActivityStep{Status: v1.ActivityStatusTypeSucceeded},
+				},
+			},
+			expectResumed: true,
+			expectState:   promote.PromoteStateCompleted,
+		},
+		{
+			name:    "closed",
+			version: "1.2.3",
+			promoteStep: &v1.PromoteActivityStep{
+				PullRequest: &v1.PromotePullRequestStep{
+					CoreActivityStep: v1.CoreActivityStep{Status: v1.ActivityStatusTypeRunning},
+					PullRequestURL:   "https://github.com/myorg/environment-staging/pull/6",
+				},
+			},
+		},
+		{
+			name:    "failed",
+			version: "1.2.3",
+			promoteStep: &v1.PromoteActivityStep{
+				PullRequest: &v1.PromotePullRequestStep{
+					CoreActivityStep: v1.CoreActivityStep{Status: v1.ActivityStatusTypeFailed},
+					PullRequestURL:   "https://github.com/myorg/environment-staging/pull/5",
+				},
+			},
+		},
+		{
+			name:    "different-version",
+			version: "2.0.0",
+			promoteStep: &v1.PromoteActivityStep{
+				PullRequest: &v1.PromotePullRequestStep{
+					CoreActivityStep: v1.CoreActivityStep{Status: v1.ActivityStatusTypeRunning},
+					PullRequestURL:   "https://github.com/myorg/environment-staging/pull/5",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.promoteStep.Environment = "staging"
+			activity := &v1.PipelineActivity{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "myorg-myapp-main-3",
+					Namespace: "jx",
+				},
+				Spec: v1.PipelineActivitySpec{
+					Version: "1.2.3",
+					Steps: []v1.PipelineActivityStep{
+						{
+							Kind:    v1.ActivityStepKindTypePromote,
+							Promote: tc.promoteStep,
+						},
+					},
+				},
+			}
+			po, scmData, remoteDir := newTestGitOpsOptions(t)
+			po.JXClient = v1fake.NewSimpleClientset(activity)
+			scmData.PullRequests[5] = &scm.PullRequest{
+				Number:    5,
+				Link:      "https://github.com/myorg/environment-staging/pull/5",
+				Mergeable: true,
+				Base: scm.PullRequestBranch{
+					Repo: scm.Repository{FullName: "myorg/environment-staging"},
+				},
+				Head: scm.PullRequestBranch{Sha: "head123"},
+			}
+			scmData.PullRequests[6] = &scm.PullRequest{
+				Number: 6,
+				Link:   "https://github.com/myorg/environment-staging/pull/6",
+				Closed: true,
+			}
+			env := &po.DevEnvContext.Requirements.Environments[0]
+			if len(tc.requiredChecks) > 0 {
+				// lets configure the required checks in the environment repository
+				createTestEnvironmentRepository(t, filepath.Dir(remoteDir), "checks", map[string]string{
+					".jx/promote.yaml": `apiVersion: promote.jenkins-x.io/v1alpha1
+kind: Promote
+spec:
+  environments:
+  - name: staging
+    requiredChecks:
+    - ` + strings.Join(tc.requiredChecks, "\n    - ") + `
+`,
+				})
+				env.GitURL = testGitURLPrefix + "environment-checks.git"
+			}
+			promoteKey := &activities.PromoteStepActivityKey{
+				PipelineActivityKey: activities.PipelineActivityKey{Name: activity.Name},
+				Environment:         env.Key,
+			}
+
+			releaseInfo := &promote.ReleaseInfo{Version: tc.version}
+			resumed, err := po.ResumePromotion(env, releaseInfo, promoteKey)
+			require.NoError(t, err, "failed to resume promotion")
+			require.Equal(t, tc.expectResumed, resumed, "resumed")
+			if !resumed {
+				return
+			}
+			assert.Equal(t, tc.expectState, releaseInfo.State, "state")
+			assert.Equal(t, activity.Name, releaseInfo.PipelineActivity, "PipelineActivity")
+			if tc.expectPR > 0 {
+				require.NotNil(t, releaseInfo.PullRequestInfo, "PullRequestInfo")
+				assert.Equal(t, tc.expectPR, releaseInfo.PullRequestInfo.Number, "Pull Request number")
+			} else {
+				assert.Equal(t, "abc123", releaseInfo.MergeSHA, "MergeSHA")
+			}
+			if len(tc.requiredChecks) == 0 {
+				return
+			}
+
+			// lets check we wait for the required checks of the environment rather than the combined status
+			var status *scm.Status
+			timeout := time.Minute
+			pollDuration := time.Millisecond
+			po.TimeoutDuration = &timeout
+			po.PullRequestPollDuration = &pollDuration
+			po.PullRequestHandlers = map[promote.PullRequestState]promote.PullRequestHandler{
+				promote.PullRequestStatePending: func(_ *promote.PullRequestWaiter, poll *promote.PullRequestPoll) (bool, error) {
+					status = poll.Status
+					return true, nil
+				},
+			}
+			err = po.WaitForPromotion(env, releaseInfo)
+			require.NoError(t, err, "failed to wait for the promotion")
+			require.NotNil(t, status, "status")
+			assert.Equal(t, promote.RequiredChecksLabel, status.Label, "status label")
+			assert.Contains(t, status.Desc, "ci/test (missing)", "status description")
+		})
+	}
+}
+
+// fakeClock advances the time by the duration slept and invokes onSleep so tests can change the Pull Request between polls.
+// The channels returned by After fire immediately, once the time has passed the duration like a real timer, unless
+// holdAfter is set
+type fakeClock struct {
+	now       time.Time
+	sleeps    int
+	afters    int
+	holdAfter bool
+	onSleep   func(sleeps int)
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+	c.sleeps++
+	if c.onSleep != nil {
+		c.onSleep(c.sleeps)
+	}
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.afters++
+	ch := make(chan time.Time, 1)
+	if !c.holdAfter {
+		c.now = c.now.Add(d + time.Nanosecond)
+		ch <- c.now
+	}
+	return ch
+}
+
+func TestWaitForPullRequest(t *testing.T) {
+	const headSha = "head123"
+	testCases := []struct {
+		name            string
+		pr              scm.PullRequest
+		status          scm.State
+		onSleep         func(pr *scm.PullRequest, sleeps int)
+		expectError     string
+		expectState     promote.PromoteState
+		expectMergeSHA  string
+		expectSleeps    int
+		expectConflicts int
+	}{
+		{
+			name: "merged-without-sha",
+			pr:   scm.PullRequest{Merged: true, Closed: true},
+			onSleep: func(pr *scm.PullRequest, sleeps int) {
+				if sleeps == 2 {
+					pr.MergeSha = "merge123"
+				}
+			},
+			expectState:    promote.PromoteStateMerged,
+			expectMergeSHA: "merge123",
+			expectSleeps:   2,
+		},
+		{
+			name:        "closed",
+			pr:          scm.PullRequest{Closed: true},
+			expectError: "is closed without merging",
+			expectState: promote.PromoteStateClosed,
+		},
+		{
+			name:        "failed",
+			pr:          scm.PullRequest{Mergeable: true},
+			status:      scm.StateFailure,
+			expectError: "last commit has status failure",
+			expectState: promote.PromoteStateFailed,
+		},
+		{
+			name:   "succeeded-merges",
+			pr:     scm.PullRequest{Mergeable: true},
+			status: scm.StateSuccess,
+			onSleep: func(pr *scm.PullRequest, _ int) {
+				if pr.Merged {
+					pr.MergeSha = "merge456"
+				}
+			},
+			expectState:    promote.PromoteStateMerged,
+			expectMergeSHA: "merge456",
+			expectSleeps:   1,
+		},
+		{
+			name:            "conflict",
+			pr:              scm.PullRequest{MergeableState: scm.MergeableStateConflicting},
+			status:          scm.StatePending,
+			expectError:     "rebased",
+			expectConflicts: 1,
+		},
+		{
+			name:   "flapping-mergeability",
+			pr:     scm.PullRequest{},
+			status: scm.StatePending,
+			onSleep: func(pr *scm.PullRequest, sleeps int) {
+				switch sleeps {
+				case 1:
+					pr.Mergeable = true
+				case 2:
+					pr.Mergeable = false
+				case 3:
+					pr.Merged = true
+					pr.MergeSha = "merge789"
+				}
+			},
+			expectState:    promote.PromoteStateMerged,
+			expectMergeSHA: "merge789",
+			expectSleeps:   3,
+		},
+		{
+			name:        "timed-out",
+			pr:          scm.PullRequest{Mergeable: true},
+			status:      scm.StatePending,
+			expectError: "timed out waiting for pull request",
+			expectState: promote.PromoteStateTimedOut,
+			// the poll after the 4th sleep is after the end time
+			expectSleeps: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scmClient, scmData := scmfake.NewDefault()
+			pr := tc.pr
+			pr.Number = 1
+			pr.Link = "https://github.com/myorg/environment-staging/pull/1"
+			pr.Base.Repo = scm.Repository{Namespace: "myorg", Name: "environment-staging", FullName: "myorg/environment-staging"}
+			pr.Head.Sha = headSha
+			scmData.PullRequests[1] = &pr
+			if tc.status != scm.StateUnknown {
+				scmData.Statuses[headSha] = []*scm.Status{{Label: "verify", State: tc.status}}
+			}
+
+			pollDuration := time.Second
+			clock := &fakeClock{now: time.Now()}
+			clock.onSleep = func(sleeps int) {
+				if tc.onSleep != nil {
+					tc.onSleep(&pr, sleeps)
+				}
+			}
+			conflicts := 0
+			po := &promote.Options{
+				Namespace:               "jx",
+				KubeClient:              kubefake.NewSimpleClientset(),
+				JXClient:                v1fake.NewSimpleClientset(),
+				NoWaitAfterMerge:        true,
+				PullRequestPollDuration: &pollDuration,
+				Clock:                   clock,
+				PullRequestHandlers: map[promote.PullRequestState]promote.PullRequestHandler{
+					promote.PullRequestStateConflict: func(_ *promote.PullRequestWaiter, _ *promote.PullRequestPoll) (bool, error) {
+						conflicts++
+						return false, fmt.Errorf("rebased")
+					},
+				},
+			}
+			po.ScmClient = scmClient
+
+			env := &jxcore.EnvironmentConfig{Key: "staging"}
+			releaseInfo := &promote.ReleaseInfo{Version: "1.2.3", PullRequestInfo: &pr}
+			promoteKey := &activities.PromoteStepActivityKey{
+				PipelineActivityKey: activities.PipelineActivityKey{Name: "myorg-myapp-main-1"},
+				Environment:         env.Key,
+			}
+			end := clock.now.Add(3 * pollDuration)
+			err := po.NewPullRequestWaiter(env, releaseInfo, end, 3*pollDuration, promoteKey).Wait()
+			if tc.expectError != "" {
+				require.Error(t, err, "should fail")
+				assert.Contains(t, err.Error(), tc.expectError)
+			} else {
+				require.NoError(t, err, "failed to wait for the Pull Request")
+			}
+			assert.Equal(t, tc.expectState, releaseInfo.State, "state")
+			assert.Equal(t, tc.expectMergeSHA, releaseInfo.MergeSHA, "MergeSHA")
+			assert.Equal(t, tc.expectSleeps, clock.sleeps, "sleeps")
+			assert.Equal(t, tc.expectConflicts, conflicts, "conflicts")
+		})
+	}
+}
+
+func TestWaitForPullRequestWebhook(t *testing.T) {
+	scmClient, scmData := scmfake.NewDefault()
+	pr := &scm.PullRequest{
+		Number:    1,
+		Link:      "https://github.com/myorg/environment-staging/pull/1",
+		Base:      scm.PullRequestBranch{Repo: scm.Repository{Namespace: "myorg", Name: "environment-staging", FullName: "myorg/environment-staging"}},
+		Head:      scm.PullRequestBranch{Sha: "head123"},
+		Mergeable: true,
+	}
+	scmData.PullRequests[1] = pr
+	scmData.Statuses["head123"] = []*scm.Status{{Label: "verify", State: scm.StatePending}}
+
+	// the clock does not fire the fallback poll so the Pull Request is only polled again on a webhook
+	pollDuration := time.Hour
+	clock := &fakeClock{now: time.Now(), holdAfter: true}
+	pendingPolls := 0
+	po := &promote.Options{
+		Namespace:               "jx",
+		KubeClient:              kubefake.NewSimpleClientset(),
+		JXClient:                v1fake.NewSimpleClientset(),
+		NoWaitAfterMerge:        true,
+		PullRequestPollDuration: &pollDuration,
+		Clock:                   clock,
+		PullRequestHandlers: map[promote.PullRequestState]promote.PullRequestHandler{
+			promote.PullRequestStatePending: func(_ *promote.PullRequestWaiter, poll *promote.PullRequestPoll) (bool, error) {
+				// lets merge the Pull Request on the poll triggered by the first webhook
+				pendingPolls++
+				if pendingPolls == 2 {
+					poll.PullRequest.Merged = true
+					poll.PullRequest.MergeSha = "merge123"
+				}
+				return false, nil
+			},
+		},
+	}
+	po.ScmClient = scmClient
+
+	env := &jxcore.EnvironmentConfig{Key: "staging"}
+	releaseInfo := &promote.ReleaseInfo{Version: "1.2.3", PullRequestInfo: pr}
+	promoteKey := &activities.PromoteStepActivityKey{
+		PipelineActivityKey: activities.PipelineActivityKey{Name: "myorg-myapp-main-1"},
+		Environment:         env.Key,
+	}
+	events := make(chan struct{})
+	w := po.NewPullRequestWaiter(env, releaseInfo, clock.now.Add(2*pollDuration), 2*pollDuration, promoteKey)
+	w.Events = events
+
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Wait()
+	}()
+	var err error
+	for i := 0; i < 3; i++ {
+		select {
+		case events <- struct{}{}:
+			continue
+		case err = <-done:
+		case <-time.After(10 * time.Second):
+			require.Fail(t, "timed out waiting for the webhooks to complete the promotion")
+		}
+		break
+	}
+	require.NoError(t, err, "failed to wait for the Pull Request")
+	assert.Equal(t, 2, pendingPolls, "pending polls")
+	assert.Equal(t, promote.PromoteStateMerged, releaseInfo.State, "state")
+	assert.Equal(t, "merge123", releaseInfo.MergeSHA, "MergeSHA")
+	assert.Equal(t, 0, clock.sleeps, "sleeps")
+}
+
+func TestWaitForPullRequestWebhookTimeout(t *testing.T) {
+	scmClient, scmData := scmfake.NewDefault()
+	pr := &scm.PullRequest{
+		Number:    1,
+		Link:      "https://github.com/myorg/environment-staging/pull/1",
+		Base:      scm.PullRequestBranch{Repo: scm.Repository{Namespace: "myorg", Name: "environment-staging", FullName: "myorg/environment-staging"}},
+		Head:      scm.PullRequestBranch{Sha: "head123"},
+		Mergeable: true,
+	}
+	scmData.PullRequests[1] = pr
+	scmData.Statuses["head123"] = []*scm.Status{{Label: "verify", State: scm.StatePending}}
+
+	// without any webhooks the Pull Request is polled whenever the clock fires until the wait times out
+	pollDuration := time.Hour
+	clock := &fakeClock{now: time.Now()}
+	po := &promote.Options{
+		Namespace:               "jx",
+		KubeClient:              kubefake.NewSimpleClientset(),
+		JXClient:                v1fake.NewSimpleClientset(),
+		NoWaitAfterMerge:        true,
+		PullRequestPollDuration: &pollDuration,
+		Clock:                   clock,
+	}
+	po.ScmClient = scmClient
+
+	env := &jxcore.EnvironmentConfig{Key: "staging"}
+	releaseInfo := &promote.ReleaseInfo{Version: "1.2.3", PullRequestInfo: pr}
+	promoteKey := &activities.PromoteStepActivityKey{
+		PipelineActivityKey: activities.PipelineActivityKey{Name: "myorg-myapp-main-1"},
+		Environment:         env.Key,
+	}
+	w := po.NewPullRequestWaiter(env, releaseInfo, clock.now.Add(2*pollDuration), 2*pollDuration, promoteKey)
+	w.Events = make(chan struct{})
+
+	err := w.Wait()
+	require.Error(t, err, "should time out waiting for the Pull Request")
+	assert.Contains(t, err.Error(), "timed out waiting for pull request", "error")
+	assert.Equal(t, 2, clock.afters, "afters")
+	assert.Equal(t, 0, clock.sleeps, "sleeps")
+}
+
+func TestController(t *testing.T) {
+	ns := "jx"
+	promoteClient := promotefake.NewSimpleClientset(
+		&v1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Name: "myapp-1-2-3", Namespace: ns},
+			Spec: v1alpha1.PromotionSpec{
+				App:     "myapp",
+				Version: "1.2.3",
+			},
+		},
+		&v1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Name: "another-2-0-0", Namespace: ns},
+			Spec: v1alpha1.PromotionSpec{
+				App:          "another",
+				Version:      "2.0.0",
+				Environments: []string{"production"},
+				Strategy:     v1alpha1.PromotionStrategyAll,
+				Timeout:      "5m",
+			},
+		},
+		&v1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: ns},
+			Spec: v1alpha1.PromotionSpec{
+				App:      "invalid",
+				Version:  "1.0.0",
+				Strategy: "random",
+			},
+		},
+	)
+
+	promoted := make(chan *promote.Options, 3)
+	co := &promote.ControllerOptions{
+		Resync: "10m",
+		PromoteFunc: func(po *promote.Options) error {
+			promoted <- po
+			if po.Application == "another" {
+				return fmt.Errorf("pull request failed")
+			}
+			po.Results.Environments = []promote.EnvironmentResult{
+				{
+					Environment:    "staging",
+					State:          promote.PromoteStateMerged,
+					PullRequestURL: "https://github.com/myorg/environment-staging/pull/5",
+					MergeSHA:       "merge123",
+				},
+			}
+			return nil
+		},
+	}
+	co.PromoteClient = promoteClient
+	co.Namespace = ns
+	co.Timeout = "1h"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- co.RunController(ctx)
+	}()
+
+	promotions := promoteClient.PromoteV1alpha1().Promotions(ns)
+	getPromotion := func(name string) *v1alpha1.Promotion {
+		p, err := promotions.Get(ctx, name, metav1.GetOptions{})
+		require.NoError(t, err, "failed to get Promotion %s", name)
+		return p
+	}
+	for _, name := range []string{"myapp-1-2-3", "another-2-0-0", "invalid"} {
+		require.Eventually(t, func() bool {
+			return getPromotion(name).Status.CompletionTime != nil
+		}, 10*time.Second, 10*time.Millisecond, "Promotion %s should complete", name)
+	}
+
+	p := getPromotion("myapp-1-2-3")
+	assert.Equal(t, v1alpha1.PromotionPhaseSucceeded, p.Status.Phase, "phase")
+	assert.NotNil(t, p.Status.StartTime, "start time")
+	require.Len(t, p.Status.Environments, 1, "environments")
+	assert.Equal(t, "staging", p.Status.Environments[0].Name)
+	assert.Equal(t, "merged", p.Status.Environments[0].State)
+	assert.Equal(t, "merge123", p.Status.Environments[0].MergeSHA)
+	require.Len(t, p.Status.Conditions, 1, "conditions")
+	assert.Equal(t, metav1.ConditionTrue, p.Status.Conditions[0].Status, "condition status")
+	assert.Equal(t, "Promoted", p.Status.Conditions[0].Reason, "condition reason")
+
+	p = getPromotion("another-2-0-0")
+	assert.Equal(t, v1alpha1.PromotionPhaseFailed, p.Status.Phase, "phase")
+	require.Len(t, p.Status.Conditions, 1, "conditions")
+	assert.Equal(t, metav1.ConditionFalse, p.Status.Conditions[0].Status, "condition status")
+	assert.Equal(t, "pull request failed", p.Status.Conditions[0].Message, "condition message")
+
+	p = getPromotion("invalid")
+	assert.Equal(t, v1alpha1.PromotionPhaseFailed, p.Status.Phase, "phase")
+	assert.Contains(t, p.Status.Conditions[0].Message, "unsupported spec.strategy random")
+
+	cancel()
+	require.NoError(t, <-done, "failed to run the controller")
+
+	close(promoted)
+	options := map[string]*promote.Options{}
+	for po := range promoted {
+		options[po.Application] = po
+	}
+	require.Len(t, options, 2, "should only promote the valid Promotions")
+
+	po := options["myapp"]
+	assert.Equal(t, "1.2.3", po.Version, "version")
+	assert.Equal(t, ns, po.Namespace, "namespace")
+	assert.True(t, po.AllAutomatic, "AllAutomatic")
+	assert.False(t, po.All, "All")
+	assert.True(t, po.BatchMode, "BatchMode")
+	assert.Equal(t, "1h", po.Timeout, "timeout")
+	assert.Equal(t, "jx/myapp-1-2-3/promotion", po.Pipeline, "pipeline")
+	assert.Equal(t, "1", po.Build, "build")
+
+	po = options["another"]
+	assert.Equal(t, []string{"production"}, po.Environments, "environments")
+	assert.False(t, po.AllAutomatic, "AllAutomatic")
+	assert.Equal(t, "5m", po.Timeout, "timeout")
+
+	// a completed Promotion is not promoted again
+	err := co.Reconcile(context.Background(), ns+"/myapp-1-2-3")
+	require.NoError(t, err, "failed to reconcile")
+	assert.Equal(t, "merge123", getPromotion("myapp-1-2-3").Status.Environments[0].MergeSHA, "status should be unchanged")
+
+	err = co.Reconcile(context.Background(), ns+"/missing")
+	require.NoError(t, err, "should ignore a missing Promotion")
+}
+
+func TestRecordStatus(t *testing.T) {
+	ns := "jx"
+	promoteClient := promotefake.NewSimpleClientset()
+	po := &promote.Options{
+		StatusResource: "promote",
+		PromoteClient:  promoteClient,
+		DevPromoteConfig: &v1alpha1.Promote{
+			Spec: v1alpha1.PromoteSpec{
+				HelmfileRule: &v1alpha1.HelmfileRule{Path: "helmfile.yaml"},
+			},
+		},
+		Results: promote.PromoteResults{
+			Environments: []promote.EnvironmentResult{
+				{
+					Environment:    "staging",
+					App:            "myapp",
+					Version:        "1.2.3",
+					State:          promote.PromoteStateMerged,
+					PullRequestURL: "https://github.com/myorg/environment-staging/pull/5",
+					MergeSHA:       "merge123",
+					Policies: []promote.PolicyResult{
+						{Policy: "security-scan", Result: "allow"},
+					},
+				},
+				{
+					Environment: "production",
+					Apps: []promote.ManifestApp{
+						{App: "myapp", Version: "1.2.3"},
+						{App: "another", Version: "2.0.0"},
+					},
+					State: promote.PromoteStateFailed,
+				},
+			},
+		},
+	}
+	po.Namespace = ns
+
+	err := po.RecordStatus()
+	require.NoError(t, err, "failed to record the status")
+
+	p, err := promoteClient.PromoteV1alpha1().Promotes(ns).Get(context.TODO(), "promote", metav1.GetOptions{})
+	require.NoError(t, err, "should create the Promote resource")
+	require.NotNil(t, p.Spec.HelmfileRule, "should copy the development environment configuration")
+
+	app := promoteconfig.FindAppStatus(&p.Status, "staging", "myapp")
+	require.NotNil(t, app, "should record myapp in staging")
+	assert.Equal(t, "1.2.3", app.Version, "version")
+	assert.Equal(t, "merged", app.State, "state")
+	assert.Equal(t, "https://github.com/myorg/environment-staging/pull/5", app.PullRequestURL, "pull request URL")
+	assert.Equal(t, "merge123", app.MergeSHA, "merge SHA")
+	assert.NotNil(t, app.PromotionTime, "promotion time")
+	assert.Equal(t, []v1alpha1.PromotePolicyStatus{{Name: "security-scan", Result: "allow"}}, app.Policies, "policies")
+
+	app = promoteconfig.FindAppStatus(&p.Status, "production", "another")
+	require.NotNil(t, app, "should record another in production")
+	assert.Equal(t, "failed", app.State, "state")
+
+	po.Results.Environments = []promote.EnvironmentResult{
+		{
+			Environment: "staging",
+			App:         "myapp",
+			Version:     "1.3.0",
+			State:       promote.PromoteStateMerged,
+		},
+	}
+	err = po.RecordStatus()
+	require.NoError(t, err, "failed to record the status again")
+
+	p, err = promoteClient.PromoteV1alpha1().Promotes(ns).Get(context.TODO(), "promote", metav1.GetOptions{})
+	require.NoError(t, err, "failed to get the Promote resource")
+	assert.Equal(t, "1.3.0", promoteconfig.FindAppStatus(&p.Status, "staging", "myapp").Version, "should update the version")
+	assert.Equal(t, "1.2.3", promoteconfig.FindAppStatus(&p.Status, "production", "myapp").Version, "should keep other environments")
+}
@@ -0,0 +1,418 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/cloudevents"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/notify"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/webhook"
+	"github.com/jenkins-x/go-scm/scm"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/activities"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PullRequestState the state of a promotion Pull Request observed when polling it while waiting for it to merge
+type PullRequestState string
+
+const (
+	// PullRequestStatePending the checks of the Pull Request are in progress or their status is not yet known
+	PullRequestStatePending PullRequestState = "pending"
+
+	// PullRequestStateSucceeded the checks of the Pull Request succeeded so it can be merged
+	PullRequestStateSucceeded PullRequestState = "succeeded"
+
+	// PullRequestStateFailed the checks of the Pull Request failed
+	PullRequestStateFailed PullRequestState = "failed"
+
+	// PullRequestStateConflict the Pull Request cannot be merged due to a conflict with the base branch
+	PullRequestStateConflict PullRequestState = "conflict"
+
+	// PullRequestStateMergedWithoutSHA the Pull Request is merged but the git provider has not yet returned the merge SHA
+	PullRequestStateMergedWithoutSHA PullRequestState = "merged-without-sha"
+
+	// PullRequestStateMerged the Pull Request is merged
+	PullRequestStateMerged PullRequestState = "merged"
+
+	// PullRequestStateClosed the Pull Request was closed without merging
+	PullRequestStateClosed PullRequestState = "closed"
+
+	// PullRequestStateTimedOut the Pull Request did not merge before the timeout
+	PullRequestStateTimedOut PullRequestState = "timed-out"
+
+	// conflictPolls the number of consecutive polls a Pull Request must be unmergeable for before it is considered to
+	// conflict if the git provider does not report a conflict. This avoids rebasing while the mergeability is computed
+	conflictPolls = 2
+)
+
+// PullRequestPoll the result of polling a promotion Pull Request
+type PullRequestPoll struct {
+	// PullRequest the latest Pull Request from the git provider
+	PullRequest *scm.PullRequest
+
+	// State the state of the Pull Request
+	State PullRequestState
+
+	// Status the combined status of the checks of the Pull Request if it is open
+	Status *scm.Status
+
+	// Attempt the number of times the Pull Request has been polled starting at 1
+	Attempt int
+}
+
+// PullRequestHandler handles a state of the Pull Request returning true if waiting for the Pull Request is complete
+type PullRequestHandler func(w *PullRequestWaiter, poll *PullRequestPoll) (bool, error)
+
+// Clock provides the time and waits between polls so that tests can control time
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// PullRequestWaiter polls a promotion Pull Request and invokes the handler of the state it is in until a handler
+// completes or fails the wait
+type PullRequestWaiter struct {
+	*Options
+
+	Environment *jxcore.EnvironmentConfig
+	ReleaseInfo *ReleaseInfo
+	PromoteKey  *activities.PromoteStepActivityKey
+
+	// End the time at which waiting times out
+	End time.Time
+
+	// Timeout the duration waited used in the timeout message
+	Timeout time.Duration
+
+	// PollDuration the duration between polls
+	PollDuration time.Duration
+
+	// Clock the clock used to poll
+	Clock Clock
+
+	// Handlers the handlers of each state
+	Handlers map[PullRequestState]PullRequestHandler
+
+	// Events signals that a webhook may have changed the Pull Request so that it is polled without waiting for the
+	// PollDuration
+	Events <-chan struct{}
+
+	loggedMergeFailure   bool
+	loggedNoMergeSha     bool
+	unmergeableSequences int
+}
+
+// DefaultPullRequestHandlers returns the default handlers of each Pull Request state
+func DefaultPullRequestHandlers() map[PullRequestState]PullRequestHandler {
+	return map[PullRequestState]PullRequestHandler{
+		PullRequestStatePending:          HandlePullRequestPending,
+		PullRequestStateSucceeded:        HandlePullRequestSucceeded,
+		PullRequestStateFailed:           HandlePullRequestFailed,
+		PullRequestStateConflict:         HandlePullRequestConflict,
+		PullRequestStateMergedWithoutSHA: HandlePullRequestMergedWithoutSHA,
+		PullRequestStateMerged:           HandlePullRequestMerged,
+		PullRequestStateClosed:           HandlePullRequestClosed,
+		PullRequestStateTimedOut:         HandlePullRequestTimedOut,
+	}
+}
+
+// NewPullRequestWaiter creates a waiter for the Pull Request of the release using the default handlers overridden by
+// any PullRequestHandlers on the options
+func (o *Options) NewPullRequestWaiter(env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, end time.Time, duration time.Duration, promoteKey *activities.PromoteStepActivityKey) *PullRequestWaiter {
+	handlers := DefaultPullRequestHandlers()
+	for state, handler := range o.PullRequestHandlers {
+		handlers[state] = handler
+	}
+	clock := o.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	var pollDuration time.Duration
+	if o.webhooks != nil && o.webhookPollDuration != nil {
+		pollDuration = *o.webhookPollDuration
+	} else if o.PullRequestPollDuration != nil {
+		pollDuration = *o.PullRequestPollDuration
+	}
+	return &PullRequestWaiter{
+		Options:      o,
+		Environment:  env,
+		ReleaseInfo:  releaseInfo,
+		PromoteKey:   promoteKey,
+		End:          end,
+		Timeout:      duration,
+		PollDuration: pollDuration,
+		Clock:        clock,
+		Handlers:     handlers,
+	}
+}
+
+// Wait polls the Pull Request until a handler completes or fails the wait
+func (w *PullRequestWaiter) Wait() error {
+	if w.JXClient == nil {
+		return fmt.Errorf("no jx client")
+	}
+	if w.KubeClient == nil {
+		return fmt.Errorf("no kube client")
+	}
+	if w.ScmClient == nil {
+		return fmt.Errorf("no ScmClient")
+	}
+	pullRequestInfo := w.ReleaseInfo.PullRequestInfo
+	if pullRequestInfo == nil {
+		return nil
+	}
+	fullName := pullRequestInfo.Repository().FullName
+	prNumber := pullRequestInfo.Number
+	attrs := append(w.spanAttributes([]*jxcore.EnvironmentConfig{w.Environment}), attribute.Int("pull_request.number", prNumber))
+	if w.Events == nil && w.webhooks != nil {
+		events, unsubscribe := w.webhooks.Subscribe(w.ScmClient.Webhooks, webhook.PullRequestFilter(fullName, prNumber))
+		defer unsubscribe()
+		w.Events = events
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := w.checkLock()
+		if err != nil {
+			return err
+		}
+		end := w.StartSpan("poll pull request", attrs...)
+		done, err := w.poll(fullName, prNumber, attempt)
+		end(err)
+		if done || err != nil {
+			return err
+		}
+		w.sleep()
+	}
+}
+
+// sleep waits for the PollDuration or until a webhook signals that the Pull Request may have changed
+func (w *PullRequestWaiter) sleep() {
+	if w.Events == nil {
+		w.Clock.Sleep(w.PollDuration)
+		return
+	}
+	d := w.PollDuration
+	remaining := w.End.Sub(w.Clock.Now())
+	if remaining < d {
+		d = max(remaining, 0)
+	}
+	select {
+	case <-w.Events:
+		log.Logger().Debugf("received a webhook for Pull Request %s", w.ReleaseInfo.PullRequestInfo.Link)
+	case <-w.Clock.After(d):
+	}
+}
+
+// poll finds the Pull Request, invokes the handler of its state and then the timeout handler if the wait has timed out
+func (w *PullRequestWaiter) poll(fullName string, prNumber, attempt int) (bool, error) {
+	pr, _, err := w.ScmClient.PullRequests.Find(context.Background(), fullName, prNumber)
+	if err != nil {
+		return false, fmt.Errorf("failed to find PR %s %d: %w", fullName, prNumber, err)
+	}
+	poll := w.Classify(pr)
+	poll.Attempt = attempt
+	log.Logger().Debugf("Pull Request %s is %s", pr.Link, poll.State)
+
+	done, err := w.handle(poll)
+	if done || err != nil {
+		return done, err
+	}
+	if w.Clock.Now().After(w.End) {
+		poll.State = PullRequestStateTimedOut
+		return w.handle(poll)
+	}
+	return false, nil
+}
+
+// handle invokes the handler of the state of the poll
+func (w *PullRequestWaiter) handle(poll *PullRequestPoll) (bool, error) {
+	handler := w.Handlers[poll.State]
+	if handler == nil {
+		return false, nil
+	}
+	return handler(w, poll)
+}
+
+// Classify determines the state of the Pull Request, querying the status of its checks if it is open
+func (w *PullRequestWaiter) Classify(pr *scm.PullRequest) *PullRequestPoll {
+	poll := &PullRequestPoll{PullRequest: pr}
+	switch {
+	case pr.Merged && pr.MergeSha == "":
+		poll.State = PullRequestStateMergedWithoutSHA
+		return poll
+	case pr.Merged:
+		poll.State = PullRequestStateMerged
+		return poll
+	case pr.Closed:
+		poll.State = PullRequestStateClosed
+		return poll
+	}
+
+	var err error
+	if len(w.requiredChecks) > 0 {
+		poll.Status, err = w.PullRequestRequiredChecksStatus(pr, w.requiredChecks)
+	} else {
+		poll.Status, err = w.PullRequestLastCommitStatus(pr)
+	}
+	if err != nil {
+		log.Logger().Warnf("Failed to query the Pull Request last commit status for %s ref %s %s", pr.Link, w.pullRequestLastCommitSha(pr), err)
+	}
+	if poll.Status != nil && StateIsErrorOrFailure(poll.Status) {
+		poll.State = PullRequestStateFailed
+		return poll
+	}
+
+	if pr.Mergeable {
+		w.unmergeableSequences = 0
+	} else {
+		w.unmergeableSequences++
+		if pr.MergeableState == scm.MergeableStateConflicting || w.unmergeableSequences >= conflictPolls {
+			poll.State = PullRequestStateConflict
+			return poll
+		}
+	}
+
+	if poll.Status != nil && poll.Status.State == scm.StateSuccess {
+		poll.State = PullRequestStateSucceeded
+	} else {
+		poll.State = PullRequestStatePending
+	}
+	return poll
+}
+
+// HandlePullRequestPending logs the progress of the checks of the Pull Request
+func HandlePullRequestPending(_ *PullRequestWaiter, poll *PullRequestPoll) (bool, error) {
+	status := poll.Status
+	switch {
+	case status == nil:
+	case StateIsPending(status) && status.Label == RequiredChecksLabel:
+		log.Logger().Infof("The %s for the Pull Request last commit are currently in progress.", status.Desc)
+	case StateIsPending(status):
+		log.Logger().Info("The build for the Pull Request last commit is currently in progress.")
+	default:
+		log.Logger().Infof("got git provider status %s from PR %s", status.State.String(), poll.PullRequest.Link)
+	}
+	return false, nil
+}
+
+// HandlePullRequestSucceeded merges the Pull Request unless merging is disabled or tide is merging it
+func HandlePullRequestSucceeded(w *PullRequestWaiter, poll *PullRequestPoll) (bool, error) {
+	if w.NoMergePullRequest {
+		return false, nil
+	}
+	ctx := context.Background()
+	pr := poll.PullRequest
+	fullName := pr.Repository().FullName
+
+	// lets check if tide is running or not
+	commitStatuses, _, err := w.ScmClient.Repositories.ListStatus(ctx, fullName, w.pullRequestLastCommitSha(pr), &scm.ListOptions{})
+	if err != nil {
+		log.Logger().Warnf("unable to get commit statuses for %s", pr.Link)
+	} else {
+		for _, s := range commitStatuses {
+			if s.Label == "tide" {
+				return false, nil
+			}
+		}
+	}
+
+	prMergeOptions, err := w.mergeOptions(w.Environment, w.ReleaseInfo, pr)
+	if err != nil {
+		return false, err
+	}
+	end := w.StartSpan("merge", w.spanAttributes([]*jxcore.EnvironmentConfig{w.Environment})...)
+	_, err = w.ScmClient.PullRequests.Merge(ctx, fullName, pr.Number, prMergeOptions)
+	end(err)
+	if err != nil && !w.loggedMergeFailure {
+		w.loggedMergeFailure = true
+		log.Logger().Warnf("Failed to merge the Pull Request %s due to %s maybe I don't have karma?", pr.Link, err)
+		w.Notify(notify.EventMergeFailed, w.Environment, w.ReleaseInfo, err.Error())
+	}
+	return false, nil
+}
+
+// HandlePullRequestFailed fails the promotion as the checks of the Pull Request failed
+func HandlePullRequestFailed(w *PullRequestWaiter, poll *PullRequestPoll) (bool, error) {
+	pr := poll.PullRequest
+	status := poll.Status
+	w.ReleaseInfo.State = PromoteStateFailed
+	var err error
+	if status.Label == RequiredChecksLabel {
+		err = fmt.Errorf("pull request %s last commit has %s for ref %s", pr.Link, status.Desc, w.pullRequestLastCommitSha(pr))
+	} else {
+		err = fmt.Errorf("pull request %s last commit has status %s for ref %s", pr.Link, status.State.String(), w.pullRequestLastCommitSha(pr))
+	}
+	w.Notify(notify.EventMergeFailed, w.Environment, w.ReleaseInfo, err.Error())
+	return false, err
+}
+
+// HandlePullRequestConflict recreates the changes of the Pull Request on the latest base branch
+func HandlePullRequestConflict(w *PullRequestWaiter, _ *PullRequestPoll) (bool, error) {
+	log.Logger().Info("Rebasing PullRequest due to conflict")
+	w.unmergeableSequences = 0
+	return false, w.PromoteViaPullRequest([]*jxcore.EnvironmentConfig{w.Environment}, w.ReleaseInfo, false)
+}
+
+// HandlePullRequestMergedWithoutSHA keeps polling until the git provider returns the merge SHA
+func HandlePullRequestMergedWithoutSHA(w *PullRequestWaiter, poll *PullRequestPoll) (bool, error) {
+	if !w.loggedNoMergeSha {
+		w.loggedNoMergeSha = true
+		log.Logger().Infof("Pull Request %s is merged but waiting for Merge SHA", termcolor.ColorInfo(poll.PullRequest.Link))
+	}
+	return false, nil
+}
+
+// HandlePullRequestMerged records the merge in the PipelineActivity and completes the promotion
+func HandlePullRequestMerged(w *PullRequestWaiter, poll *PullRequestPoll) (bool, error) {
+	pr := poll.PullRequest
+	mergeSha := pr.MergeSha
+	log.Logger().Infof("Pull Request %s is merged at sha %s", termcolor.ColorInfo(pr.Link), termcolor.ColorInfo(mergeSha))
+	w.ReleaseInfo.State = PromoteStateMerged
+	w.ReleaseInfo.MergeSHA = mergeSha
+	w.Notify(notify.EventPullRequestMerged, w.Environment, w.ReleaseInfo, "")
+	mergedPR := func(a *v1.PipelineActivity, s *v1.PipelineActivityStep, ps *v1.PromoteActivityStep, p *v1.PromotePullRequestStep) error {
+		err := activities.CompletePromotionPullRequest(a, s, ps, p)
+		if err != nil {
+			return err
+		}
+		p.MergeCommitSHA = mergeSha
+		return nil
+	}
+	err := w.onPromotePullRequest(w.PromoteKey, mergedPR)
+	if err != nil {
+		return false, err
+	}
+	w.EmitCloudEvent(cloudevents.TypePullRequestMerged, w.Environment, w.ReleaseInfo, "")
+	return true, w.completePromotion(w.Environment, w.ReleaseInfo, w.End, w.PromoteKey)
+}
+
+// HandlePullRequestClosed fails the promotion as the Pull Request was closed without merging
+func HandlePullRequestClosed(w *PullRequestWaiter, poll *PullRequestPoll) (bool, error) {
+	pr := poll.PullRequest
+	log.Logger().Warnf("Pull Request %s is closed", termcolor.ColorInfo(pr.Link))
+	w.ReleaseInfo.State = PromoteStateClosed
+	w.Notify(notify.EventPullRequestClosed, w.Environment, w.ReleaseInfo, "")
+	return false, fmt.Errorf("promotion failed as Pull Request %s is closed without merging", pr.Link)
+}
+
+// HandlePullRequestTimedOut fails the promotion as the Pull Request did not merge before the timeout
+func HandlePullRequestTimedOut(w *PullRequestWaiter, poll *PullRequestPoll) (bool, error) {
+	w.ReleaseInfo.State = PromoteStateTimedOut
+	err := fmt.Errorf("timed out waiting for pull request %s to merge. Waited %s", poll.PullRequest.Link, w.Timeout.String())
+	w.Notify(notify.EventTimedOut, w.Environment, w.ReleaseInfo, err.Error())
+	return false, err
+}
@@ -0,0 +1,207 @@
+package promote
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	optionOutput = "output"
+
+	// OutputFormatJSON outputs the promotion result as JSON
+	OutputFormatJSON = "json"
+
+	// OutputFormatYAML outputs the promotion result as YAML
+	OutputFormatYAML = "yaml"
+)
+
+// PromoteState the final state of a promotion to an environment
+type PromoteState string
+
+const (
+	// PromoteStateCreated the Pull Request was created or updated but not waited on
+	PromoteStateCreated PromoteState = "created"
+
+	// PromoteStateAutoMerge the git providers native auto merge was enabled on the Pull Request
+	PromoteStateAutoMerge PromoteState = "auto-merge"
+
+	// PromoteStateMerged the Pull Request was merged
+	PromoteStateMerged PromoteState = "merged"
+
+	// PromoteStateClosed the Pull Request was closed without merging
+	PromoteStateClosed PromoteState = "closed"
+
+	// PromoteStateTimedOut timed out waiting for the Pull Request to merge
+	PromoteStateTimedOut PromoteState = "timed-out"
+
+	// PromoteStateFailed the Pull Request failed its checks or the promotion failed
+	PromoteStateFailed PromoteState = "failed"
+
+	// PromoteStateCompleted the promotion was already completed by a previous run
+	PromoteStateCompleted PromoteState = "completed"
+
+	// PromoteStateSkipped the environment was not promoted to as the promotion to an earlier environment failed
+	PromoteStateSkipped PromoteState = "skipped"
+)
+
+// PromoteResults the machine readable results of promoting to environments
+type PromoteResults struct {
+	// Environments the results for each environment promoted to
+	Environments []EnvironmentResult `json:"environments"`
+}
+
+// EnvironmentResult the result of promoting to an environment
+type EnvironmentResult struct {
+	// Environment the name of the environment
+	Environment string `json:"environment"`
+
+	// App the name of the app promoted
+	App string `json:"app,omitempty"`
+
+	// Version the version of the app promoted
+	Version string `json:"version,omitempty"`
+
+	// Apps the apps promoted if promoting many apps from a manifest
+	Apps []ManifestApp `json:"apps,omitempty"`
+
+	// GitURL the git URL of the environment repository
+	GitURL string `json:"gitURL,omitempty"`
+
+	// PullRequestNumber the number of the promotion Pull Request
+	PullRequestNumber int `json:"pullRequestNumber,omitempty"`
+
+	// PullRequestURL the URL of the promotion Pull Request
+	PullRequestURL string `json:"pullRequestURL,omitempty"`
+
+	// Labels the labels on the promotion Pull Request
+	Labels []string `json:"labels,omitempty"`
+
+	// State the final state of the promotion
+	State PromoteState `json:"state,omitempty"`
+
+	// MergeSHA the merge commit SHA if the Pull Request merged
+	MergeSHA string `json:"mergeSHA,omitempty"`
+
+	// PipelineActivity the name of the PipelineActivity recording the promotion
+	PipelineActivity string `json:"pipelineActivity,omitempty"`
+
+	// RollbackPullRequestURL the URL of the Pull Request created to rollback a failed promotion
+	RollbackPullRequestURL string `json:"rollbackPullRequestURL,omitempty"`
+
+	// Policies the decisions of the policies evaluated before promoting
+	Policies []PolicyResult `json:"policies,omitempty"`
+}
+
+// PolicyResult the decision of a policy evaluated before promoting
+type PolicyResult struct {
+	// App the name of the app the policy was evaluated for
+	App string `json:"app,omitempty"`
+
+	// Policy the name of the policy
+	Policy string `json:"policy"`
+
+	// Result either 'allow', 'deny' or 'require-manual'
+	Result string `json:"result"`
+
+	// Message the optional message of the policy describing the decision
+	Message string `json:"message,omitempty"`
+}
+
+// validateOutput validates the output format option
+func (o *Options) validateOutput() error {
+	switch o.Output {
+	case "", OutputFormatJSON, OutputFormatYAML:
+		return nil
+	default:
+		return options.InvalidOption(optionOutput, o.Output, []string{OutputFormatJSON, OutputFormatYAML})
+	}
+}
+
+// addResults adds the results of promoting the release to the group of environments
+func (o *Options) addResults(envs []*jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo) {
+	if releaseInfo == nil {
+		return
+	}
+	for _, env := range envs {
+		r := EnvironmentResult{
+			Environment:      env.Key,
+			App:              o.promotedApp(),
+			Version:          releaseInfo.Version,
+			GitURL:           env.GitURL,
+			State:            releaseInfo.State,
+			MergeSHA:         releaseInfo.MergeSHA,
+			PipelineActivity: releaseInfo.PipelineActivity,
+		}
+		if len(o.ManifestApps) > 0 {
+			r.Apps = releaseInfo.Apps
+		}
+		pr := releaseInfo.PullRequestInfo
+		if pr != nil {
+			r.PullRequestNumber = pr.Number
+			r.PullRequestURL = pr.Link
+			for _, l := range pr.Labels {
+				if l != nil {
+					r.Labels = append(r.Labels, l.Name)
+				}
+			}
+		}
+		if releaseInfo.RollbackPullRequest != nil {
+			r.RollbackPullRequestURL = releaseInfo.RollbackPullRequest.Link
+		}
+		for _, d := range releaseInfo.PolicyDecisions[env.Key] {
+			r.Policies = append(r.Policies, PolicyResult{App: d.App, Policy: d.Policy, Result: d.Result, Message: d.Message})
+		}
+		o.Results.Environments = append(o.Results.Environments, r)
+	}
+}
+
+// addSkippedResults adds the results of the groups of environments which were not promoted to
+func (o *Options) addSkippedResults(groups [][]*jxcore.EnvironmentConfig) {
+	apps := o.PromoteApps()
+	if len(apps) == 0 {
+		return
+	}
+	for _, group := range groups {
+		releaseInfo := o.newReleaseInfo(apps, group)
+		releaseInfo.State = PromoteStateSkipped
+		o.addResults(group, releaseInfo)
+	}
+}
+
+// WriteResults writes the results of the promotion in the output format to the output file or standard output
+func (o *Options) WriteResults() error {
+	if o.Output == "" {
+		return nil
+	}
+	var data []byte
+	var err error
+	if o.Output == OutputFormatJSON {
+		data, err = json.MarshalIndent(&o.Results, "", "  ")
+	} else {
+		data, err = yaml.Marshal(&o.Results)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal promotion results as %s: %w", o.Output, err)
+	}
+
+	var out io.Writer = os.Stdout
+	if o.OutputFile != "" {
+		f, err := os.Create(o.OutputFile)
+		if err != nil {
+			return fmt.Errorf("failed to create output file %s: %w", o.OutputFile, err)
+		}
+		defer f.Close() //nolint:errcheck
+		out = f
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to write promotion results: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/activities"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const optionNoResume = "no-resume"
+
+// ResumePromotion re-attaches to the promotion of the same version to the environment recorded in the PipelineActivity
+// by a previous run of this pipeline, such as one whose pod was killed while waiting for the Pull Request to merge.
+// Returns true if the promotion was resumed in which case the releaseInfo is populated with the Pull Request, merge SHA
+// and state of the promotion so that we can continue waiting rather than promoting again
+func (o *Options) ResumePromotion(env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, promoteKey *activities.PromoteStepActivityKey) (bool, error) {
+	if o.NoResume || o.EnvDir != "" || o.JXClient == nil || !promoteKey.IsValid() {
+		return false, nil
+	}
+	ctx := context.TODO()
+	a, err := o.JXClient.JenkinsV1().PipelineActivities(o.Namespace).Get(ctx, promoteKey.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get PipelineActivity %s in namespace %s: %w", promoteKey.Name, o.Namespace, err)
+	}
+	if a.Spec.Version != "" && releaseInfo.Version != "" && a.Spec.Version != releaseInfo.Version {
+		return false, nil
+	}
+	step := findPromoteStep(a, env.Key)
+	if step == nil || step.PullRequest == nil {
+		return false, nil
+	}
+	prStep := step.PullRequest
+	if prStep.Status == v1.ActivityStatusTypeFailed || (prStep.PullRequestURL == "" && prStep.MergeCommitSHA == "") {
+		return false, nil
+	}
+
+	if prStep.PullRequestURL != "" {
+		pr, err := o.findPullRequest(env, prStep.PullRequestURL)
+		if err != nil {
+			return false, err
+		}
+		if pr.Closed && !pr.Merged {
+			log.Logger().Infof("not resuming the promotion to %s as Pull Request %s is closed", env.Key, pr.Link)
+			return false, nil
+		}
+		releaseInfo.PullRequestInfo = pr
+	}
+	releaseInfo.PipelineActivity = a.Name
+	releaseInfo.MergeSHA = prStep.MergeCommitSHA
+	switch {
+	case releaseInfo.MergeSHA == "":
+		releaseInfo.State = PromoteStateCreated
+		log.Logger().Infof("resuming the promotion to %s by waiting for Pull Request %s", termcolor.ColorInfo(env.Key), termcolor.ColorInfo(prStep.PullRequestURL))
+	case step.Update != nil && step.Update.Status == v1.ActivityStatusTypeSucceeded:
+		releaseInfo.State = PromoteStateCompleted
+		log.Logger().Infof("the promotion to %s was already completed at sha %s", termcolor.ColorInfo(env.Key), termcolor.ColorInfo(releaseInfo.MergeSHA))
+	default:
+		releaseInfo.State = PromoteStateMerged
+		log.Logger().Infof("resuming the promotion to %s which merged at sha %s", termcolor.ColorInfo(env.Key), termcolor.ColorInfo(releaseInfo.MergeSHA))
+	}
+	if releaseInfo.State != PromoteStateCompleted {
+		// lets wait for the same checks as if we had created the Pull Request
+		envs := releaseInfo.Environments
+		if len(envs) == 0 {
+			envs = []*jxcore.EnvironmentConfig{env}
+		}
+		err = o.loadEnvironmentChecks(envs)
+		if err != nil {
+			return false, fmt.Errorf("failed to load the checks of environment %s: %w", env.Key, err)
+		}
+	}
+	return true, nil
+}
+
+// findPromoteStep returns the promote step of the environment in the PipelineActivity
+func findPromoteStep(a *v1.PipelineActivity, envName string) *v1.PromoteActivityStep {
+	for i := range a.Spec.Steps {
+		step := a.Spec.Steps[i].Promote
+		if step != nil && step.Environment == envName {
+			return step
+		}
+	}
+	return nil
+}
+
+// findPullRequest finds the Pull Request in the environment git repository from its URL
+func (o *Options) findPullRequest(env *jxcore.EnvironmentConfig, link string) (*scm.PullRequest, error) {
+	number, err := strconv.Atoi(path.Base(strings.TrimSuffix(link, "/")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the Pull Request number from %s: %w", link, err)
+	}
+	gitURL, err := o.environmentGitURL(env)
+	if err != nil {
+		return nil, err
+	}
+	scmClient, repoFullName, err := o.GetScmClient(gitURL, o.GitKind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ScmClient: %w", err)
+	}
+	if scmClient == nil {
+		return nil, fmt.Errorf("no ScmClient for %s", gitURL)
+	}
+	pr, _, err := scmClient.PullRequests.Find(context.TODO(), repoFullName, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Pull Request %s: %w", link, err)
+	}
+	return pr, nil
+}
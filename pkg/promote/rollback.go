@@ -0,0 +1,150 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
+	"github.com/jenkins-x/go-scm/scm"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// LabelRollback the label added to rollback Pull Requests
+const LabelRollback = "rollback"
+
+// RollbackApps returns the apps with the versions they were at in the environment before the promotion by reading the
+// environment git repository at the parent of the merge commit of the promotion. Apps which were not previously in the
+// environment are omitted
+func (o *Options) RollbackApps(env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo) ([]ManifestApp, error) {
+	if releaseInfo.MergeSHA == "" {
+		return nil, fmt.Errorf("cannot find the previous versions in environment %s as the promotion has no merge commit", env.Key)
+	}
+	gitURL, err := o.environmentGitURL(env)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := o.cloneEnvironment(gitURL)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	_, err = o.Git().Command(dir, "checkout", releaseInfo.MergeSHA+"^")
+	if err != nil {
+		return nil, fmt.Errorf("failed to checkout the parent of merge commit %s in environment %s: %w", releaseInfo.MergeSHA, env.Key, err)
+	}
+
+	var answer []ManifestApp
+	for _, app := range releaseInfo.Apps {
+		release, err := o.environmentRelease(dir, env, app)
+		if err != nil {
+			return nil, err
+		}
+		version := ""
+		if release != nil {
+			version = release.Version
+		}
+		if version == "" {
+			log.Logger().Warnf("cannot rollback %s in environment %s as there was no previous version", app.App, env.Key)
+			continue
+		}
+		if version == app.Version {
+			continue
+		}
+		app.Version = version
+		answer = append(answer, app)
+	}
+	return answer, nil
+}
+
+// Rollback creates a Pull Request which restores the previous versions of the promoted apps in the environments
+// after a failed promotion and links it to the promotion Pull Request
+func (o *Options) Rollback(envs []*jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, reason string) (*scm.PullRequest, error) {
+	if len(envs) == 0 {
+		return nil, fmt.Errorf("no environments to rollback")
+	}
+	env := envs[0]
+	apps, err := o.RollbackApps(env, releaseInfo)
+	if err != nil {
+		return nil, err
+	}
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("no previous versions to rollback to in environment %s", env.Key)
+	}
+	gitURL, err := o.environmentGitURL(env)
+	if err != nil {
+		return nil, err
+	}
+
+	var names, lines []string
+	for _, app := range apps {
+		names = append(names, app.App)
+		lines = append(lines, fmt.Sprintf("* %s to version %s", app.App, app.Version))
+	}
+	labels := []string{LabelRollback}
+	for _, e := range envs {
+		labels = append(labels, "env/"+e.Key)
+	}
+	message := fmt.Sprintf("the promotion failed: %s\n\nthis rolls back:\n\n%s", reason, strings.Join(lines, "\n"))
+	failedPR := releaseInfo.PullRequestInfo
+	if failedPR != nil {
+		message += fmt.Sprintf("\n\nwhich were promoted by %s", failedPR.Link)
+	} else if releaseInfo.MergeSHA != "" {
+		message += fmt.Sprintf("\n\nwhich were promoted by commit %s", releaseInfo.MergeSHA)
+	}
+
+	// lets save the options of the promotion so we can create a separate Pull Request
+	saved := o.EnvironmentPullRequestOptions
+	defer func() {
+		o.EnvironmentPullRequestOptions = saved
+	}()
+	o.BranchName = ""
+	o.PullRequestFilter = nil
+	o.PullRequestNumber = 0
+	o.DirectPush = false
+	o.CommitTitle = fmt.Sprintf("fix: rollback %s in %s", strings.Join(names, ", "), env.Key)
+	o.CommitMessage = message
+	o.CommitChangelog = ""
+	o.Function = func() error {
+		dir := o.OutDir
+		for _, e := range envs {
+			promoteConfig, _, err := promoteconfig.Discover(dir, EnvironmentNamespace(e))
+			if err != nil {
+				return fmt.Errorf("failed to discover the PromoteConfig in dir %s: %w", dir, err)
+			}
+			for _, app := range apps {
+				r, err := o.newPromoteRule(dir, promoteConfig, app, len(apps) > 1)
+				if err != nil {
+					return err
+				}
+				err = applyPromoteRule(r)
+				if err != nil {
+					return fmt.Errorf("failed to rollback %s in %s: %w", app.App, e.Key, err)
+				}
+			}
+		}
+		return nil
+	}
+
+	pr, err := o.Create(gitURL, "", labels, o.RollbackAutoMerge)
+	if err != nil {
+		return pr, fmt.Errorf("failed to create rollback Pull Request: %w", err)
+	}
+	if pr == nil {
+		return nil, fmt.Errorf("no rollback Pull Request created for %s", gitURL)
+	}
+	log.Logger().Infof("created rollback Pull Request %s", termcolor.ColorInfo(pr.Link))
+
+	if failedPR != nil && o.ScmClient != nil {
+		comment := fmt.Sprintf("the promotion failed: %s\n\nrolling back via %s", reason, pr.Link)
+		_, _, err = o.ScmClient.PullRequests.CreateComment(context.Background(), failedPR.Repository().FullName, failedPR.Number, &scm.CommentInput{Body: comment})
+		if err != nil {
+			log.Logger().Warnf("failed to comment on Pull Request %s: %s", failedPR.Link, err)
+		}
+	}
+	return pr, nil
+}
@@ -0,0 +1,61 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/rollout"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// WaitForRollout waits until the promoted releases are healthy in the namespaces of the environments or the end
+// time is reached
+func (o *Options) WaitForRollout(envs []*jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, end time.Time) error {
+	kubeClient := o.KubeClient
+	if kubeClient == nil {
+		return fmt.Errorf("no kube client")
+	}
+	pollDuration := 20 * time.Second
+	if o.PullRequestPollDuration != nil {
+		pollDuration = *o.PullRequestPollDuration
+	}
+	ctx := context.Background()
+	lastMessage := ""
+	for {
+		var pending []string
+		for _, env := range envs {
+			ns := EnvironmentNamespace(env)
+			for _, app := range releaseInfo.Apps {
+				releaseName := app.ReleaseName
+				if releaseName == "" {
+					releaseName = app.App
+				}
+				result, err := rollout.Check(ctx, kubeClient, ns, releaseName, app.Version)
+				if err != nil {
+					return fmt.Errorf("failed to check the rollout of %s in namespace %s: %w", releaseName, ns, err)
+				}
+				for _, p := range result.Pending {
+					pending = append(pending, fmt.Sprintf("%s: %s", ns, p))
+				}
+			}
+		}
+		if len(pending) == 0 {
+			log.Logger().Infof("the promoted releases are rolled out and ready")
+			return nil
+		}
+		message := strings.Join(pending, ", ")
+		if time.Now().After(end) {
+			releaseInfo.State = PromoteStateTimedOut
+			return fmt.Errorf("timed out waiting for the promoted releases to roll out: %s", message)
+		}
+		if message != lastMessage {
+			lastMessage = message
+			log.Logger().Infof("waiting for the rollout: %s", termcolor.ColorStatus(message))
+		}
+		time.Sleep(pollDuration)
+	}
+}
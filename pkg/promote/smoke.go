@@ -0,0 +1,171 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	defaultSmokeCheckRetries       = 3
+	defaultSmokeCheckRetryInterval = 5 * time.Second
+)
+
+// SmokeCheckContext the context used to evaluate the URL template of a smoke check
+type SmokeCheckContext struct {
+	// URL the discovered URL of the application
+	URL string
+
+	// App the name of the application
+	App string
+
+	// Version the version of the application promoted
+	Version string
+
+	// Environment the name of the environment
+	Environment string
+
+	// Namespace the namespace of the environment
+	Namespace string
+}
+
+// RunSmokeChecks runs the smoke checks configured for the environments against the promoted applications
+func (o *Options) RunSmokeChecks(envs []*jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo) error {
+	for _, env := range envs {
+		checks := o.smokeChecks[env.Key]
+		if len(checks) == 0 {
+			continue
+		}
+		ns := EnvironmentNamespace(env)
+		for _, app := range releaseInfo.Apps {
+			appURL, _, err := o.FindApplicationURL(ns, app.App)
+			if err != nil {
+				return fmt.Errorf("failed to find the URL of %s in namespace %s: %w", app.App, ns, err)
+			}
+			if appURL != "" && !strings.Contains(appURL, "://") {
+				appURL = "http://" + appURL
+			}
+			ctx := &SmokeCheckContext{
+				URL:         appURL,
+				App:         app.App,
+				Version:     app.Version,
+				Environment: env.Key,
+				Namespace:   ns,
+			}
+			for i := range checks {
+				err = RunSmokeCheck(context.Background(), o.HTTPClient, &checks[i], ctx)
+				if err != nil {
+					releaseInfo.State = PromoteStateFailed
+					return fmt.Errorf("smoke check failed for %s in environment %s: %w", app.App, env.Key, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// RunSmokeCheck runs the smoke check retrying until it passes or the retries are exhausted
+func RunSmokeCheck(ctx context.Context, httpClient *http.Client, check *v1alpha1.SmokeCheck, templateContext *SmokeCheckContext) error {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	u, err := smokeCheckURL(check, templateContext)
+	if err != nil {
+		return err
+	}
+	if u == "" {
+		return fmt.Errorf("no URL for smoke check %s as the application URL could not be discovered", check.Name)
+	}
+	expectedStatus := check.ExpectedStatus
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+	var bodyRegex *regexp.Regexp
+	if check.BodyRegex != "" {
+		bodyRegex, err = regexp.Compile(check.BodyRegex)
+		if err != nil {
+			return fmt.Errorf("failed to parse body regex %s: %w", check.BodyRegex, err)
+		}
+	}
+	retries := defaultSmokeCheckRetries
+	if check.Retries != nil {
+		retries = *check.Retries
+	}
+	interval := defaultSmokeCheckRetryInterval
+	if check.RetryInterval != "" {
+		interval, err = time.ParseDuration(check.RetryInterval)
+		if err != nil {
+			return fmt.Errorf("failed to parse retry interval %s: %w", check.RetryInterval, err)
+		}
+	}
+
+	name := check.Name
+	if name == "" {
+		name = u
+	}
+	for i := 0; ; i++ {
+		err = invokeSmokeCheck(ctx, httpClient, u, expectedStatus, bodyRegex)
+		if err == nil {
+			log.Logger().Infof("smoke check %s passed", termcolor.ColorInfo(name))
+			return nil
+		}
+		if i >= retries {
+			return fmt.Errorf("smoke check %s failed after %d attempts: %w", name, i+1, err)
+		}
+		log.Logger().Warnf("smoke check %s failed so retrying: %s", name, err)
+		time.Sleep(interval)
+	}
+}
+
+func smokeCheckURL(check *v1alpha1.SmokeCheck, templateContext *SmokeCheckContext) (string, error) {
+	templateText := check.URL
+	if templateText == "" {
+		templateText = "{{.URL}}"
+	}
+	tmpl, err := template.New("smoke").Parse(templateText)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse smoke check URL template %s: %w", templateText, err)
+	}
+	buf := &strings.Builder{}
+	err = tmpl.Execute(buf, templateContext)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate smoke check URL template %s: %w", templateText, err)
+	}
+	return buf.String(), nil
+}
+
+func invokeSmokeCheck(ctx context.Context, httpClient *http.Client, u string, expectedStatus int, bodyRegex *regexp.Regexp) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request for %s: %w", u, err)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to invoke %s: %w", u, err)
+	}
+	defer res.Body.Close() //nolint:errcheck
+	if res.StatusCode != expectedStatus {
+		return fmt.Errorf("%s returned status %d but expected %d", u, res.StatusCode, expectedStatus)
+	}
+	if bodyRegex == nil {
+		return nil
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read the response body of %s: %w", u, err)
+	}
+	if !bodyRegex.Match(body) {
+		return fmt.Errorf("the response body of %s does not match %s", u, bodyRegex.String())
+	}
+	return nil
+}
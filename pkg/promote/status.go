@@ -0,0 +1,111 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
+	promoteclient "github.com/jenkins-x-plugins/jx-promote/pkg/client/clientset/versioned"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-kube-client/v3/pkg/kubeclient"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const optionStatusResource = "status-resource"
+
+// RecordStatus records the results of the promotion in the status of the --status-resource Promote resource in the
+// development namespace. The resource is created from the development environment configuration if it does not exist
+func (o *Options) RecordStatus() error {
+	if o.StatusResource == "" || o.EnvDir != "" || len(o.Results.Environments) == 0 {
+		return nil
+	}
+	err := o.lazyCreatePromoteClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	promotes := o.PromoteClient.PromoteV1alpha1().Promotes(o.Namespace)
+	now := metav1.Now()
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		p, err := promotes.Get(ctx, o.StatusResource, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			p = &v1alpha1.Promote{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      o.StatusResource,
+					Namespace: o.Namespace,
+				},
+			}
+			if o.DevPromoteConfig != nil {
+				o.DevPromoteConfig.Spec.DeepCopyInto(&p.Spec)
+			}
+			p, err = promotes.Create(ctx, p, metav1.CreateOptions{})
+		}
+		if err != nil {
+			return err
+		}
+		for i := range o.Results.Environments {
+			r := &o.Results.Environments[i]
+			if r.State == PromoteStateSkipped {
+				// lets keep the last promotion to the environment
+				continue
+			}
+			for _, app := range resultApps(r) {
+				status := &v1alpha1.PromoteAppStatus{
+					Name:             app.App,
+					Version:          app.Version,
+					State:            string(r.State),
+					PullRequestURL:   r.PullRequestURL,
+					MergeSHA:         r.MergeSHA,
+					PipelineActivity: r.PipelineActivity,
+					PromotionTime:    &now,
+				}
+				for _, pr := range r.Policies {
+					if pr.App != "" && pr.App != app.App {
+						continue
+					}
+					status.Policies = append(status.Policies, v1alpha1.PromotePolicyStatus{
+						Name:    pr.Policy,
+						Result:  pr.Result,
+						Message: pr.Message,
+					})
+				}
+				promoteconfig.SetAppStatus(&p.Status, r.Environment, status)
+			}
+		}
+		_, err = promotes.UpdateStatus(ctx, p, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the status of Promote %s in namespace %s: %w", o.StatusResource, o.Namespace, err)
+	}
+	log.Logger().Infof("recorded the promotion in the status of Promote %s", termcolor.ColorInfo(o.StatusResource))
+	return nil
+}
+
+// resultApps returns the apps promoted in the result
+func resultApps(r *EnvironmentResult) []ManifestApp {
+	if len(r.Apps) > 0 {
+		return r.Apps
+	}
+	return []ManifestApp{{App: r.App, Version: r.Version}}
+}
+
+// lazyCreatePromoteClient creates the client of the promote resources if it has not been specified
+func (o *Options) lazyCreatePromoteClient() error {
+	if o.PromoteClient != nil {
+		return nil
+	}
+	cfg, err := kubeclient.NewFactory().CreateKubeConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get kubernetes config: %w", err)
+	}
+	o.PromoteClient, err = promoteclient.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create the promote client: %w", err)
+	}
+	return nil
+}
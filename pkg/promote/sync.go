@@ -0,0 +1,359 @@
+package promote
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/rules"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/rules/factory"
+	"github.com/jenkins-x/go-scm/scm"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// LabelSync the label added to Pull Requests which synchronise environments
+const LabelSync = "sync"
+
+var (
+	syncLong = templates.LongDesc(`
+		Synchronises the apps in an environment with the versions in another environment.
+
+		Every app in the source environment which is missing or at a different version in the target environment is
+		updated in a single Pull Request on the target environment.
+
+		The promotion windows, change freezes and policies of the target environment apply to the apps being
+		synchronised in the same way as when promoting them
+`)
+
+	syncExample = templates.Examples(`
+		# Bring production up to date with all the apps in staging
+		jx promote sync --from staging --to production
+
+		# Only synchronise some of the apps
+		jx promote sync --from staging --to production --app myapp --app another
+	`)
+)
+
+// SyncOptions the options for synchronising environments
+type SyncOptions struct {
+	Options
+
+	// From the name of the environment to synchronise from
+	From string
+
+	// To the name of the environment to synchronise
+	To string
+
+	// Apps the optional names of the apps to synchronise. Defaults to all apps
+	Apps []string
+
+	// SyncedApps the apps which were updated in the target environment
+	SyncedApps []SyncApp
+}
+
+// SyncApp an app which differs between the environments
+type SyncApp struct {
+	ManifestApp
+
+	// PreviousVersion the version in the target environment before synchronising or empty if it was not present
+	PreviousVersion string
+}
+
+// NewCmdSync creates the new command for: jx promote sync
+func NewCmdSync() (*cobra.Command, *SyncOptions) {
+	o := &SyncOptions{}
+	cmd := &cobra.Command{
+		Use:     "sync",
+		Short:   "Synchronises the apps in an environment with the versions in another environment",
+		Long:    syncLong,
+		Example: syncExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.From, "from", "", "", "The environment to synchronise the versions from")
+	cmd.Flags().StringVarP(&o.To, "to", "", "", "The environment to synchronise")
+	cmd.Flags().StringArrayVarP(&o.Apps, "app", "a", nil, "The names of the apps to synchronise. Defaults to all apps in the --from environment")
+	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "The Namespace of the development environment")
+	cmd.Flags().BoolVarP(&o.BatchMode, "batch-mode", "b", false, "Enables batch mode which avoids prompting for user input")
+	cmd.Flags().StringVarP(&o.EnvDir, "env-dir", "", "", "The directory of an already checked out git repository of the --to environment to synchronise directly without creating a Pull Request")
+	cmd.Flags().BoolVarP(&o.EnvDirCommit, "env-dir-commit", "", false, "Commits the changes made to the --env-dir directory")
+	cmd.Flags().StringVarP(&o.DevEnvContext.GitUsername, "git-user", "", "", "Git username used to clone the development environment. If not specified its loaded from the git credentials file")
+	cmd.Flags().StringVarP(&o.DevEnvContext.GitToken, "git-token", "", "", "Git token used to clone the development environment. If not specified its loaded from the git credentials file")
+	cmd.Flags().StringVarP(&o.Timeout, optionTimeout, "t", "1h", "The timeout to wait to acquire the --lock")
+	cmd.Flags().BoolVarP(&o.Lock, optionLock, "", false, "Acquires a lock of the environment git repository using a Lease in the development namespace while creating the Pull Request so that it does not race with promotions")
+	cmd.Flags().StringVarP(&o.LockTTL, optionLockTTL, "", "2m", "The duration of the --lock Lease after which another promotion can take it over if it is not renewed")
+	cmd.Flags().StringVarP(&o.OverrideFreeze, optionOverrideFreeze, "", "", "The reason for synchronising outside of the promotion window or during a change freeze of the --to environment. The reason is recorded on the Pull Request")
+	cmd.Flags().StringVarP(&o.Author, "author", "", "", "The author of the change used when evaluating promotion policies if it cannot be found from the latest commit in the current directory")
+	cmd.Flags().StringToStringVarP(&o.PolicyLabels, "policy-label", "", nil, "A label in the form 'key=value' used when evaluating promotion policies. Labels of the Release resources take precedence")
+	return cmd, o
+}
+
+// Run implements this command
+func (o *SyncOptions) Run() error {
+	if o.From == "" {
+		return fmt.Errorf("missing option: --from")
+	}
+	if o.To == "" {
+		return fmt.Errorf("missing option: --to")
+	}
+	if o.From == o.To {
+		return fmt.Errorf("cannot synchronise environment %s with itself", o.From)
+	}
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+	if o.GitClient == nil {
+		o.GitClient = cli.NewCLIClient("", o.CommandRunner)
+	}
+	if o.Timeout != "" {
+		duration, err := time.ParseDuration(o.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid duration format %s for option --%s: %w", o.Timeout, optionTimeout, err)
+		}
+		o.TimeoutDuration = &duration
+	}
+	return o.traceCommand("sync", func() error {
+		err := o.lazyLoad()
+		if err != nil {
+			return fmt.Errorf("failed to lazy load the EnvironmentContext: %w", err)
+		}
+		if o.DevPromoteConfig == nil {
+			o.DevPromoteConfig, err = promoteconfig.LoadDevPromote(o.DevEnvContext.DevDir)
+			if err != nil {
+				return fmt.Errorf("failed to load the promote configuration of the dev environment: %w", err)
+			}
+		}
+		return o.Sync()
+	})
+}
+
+// Sync creates a Pull Request on the target environment updating the apps which differ from the source environment
+func (o *SyncOptions) Sync() error {
+	fromEnv := o.findEnvironment(o.From)
+	if fromEnv == nil {
+		return fmt.Errorf("could not find environment %s", o.From)
+	}
+	toEnv := o.findEnvironment(o.To)
+	if toEnv == nil {
+		return fmt.Errorf("could not find environment %s", o.To)
+	}
+	fromGitURL, err := o.environmentGitURL(fromEnv)
+	if err != nil {
+		return fmt.Errorf("failed to find the git URL of environment %s: %w", fromEnv.Key, err)
+	}
+	fromDir, err := o.cloneEnvironment(fromGitURL)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(fromDir) //nolint:errcheck
+
+	fromReleases, err := o.environmentReleases(fromDir, fromEnv)
+	if err != nil {
+		return err
+	}
+	var filtered []*rules.Release
+	for _, r := range fromReleases {
+		if len(o.Apps) == 0 || Contains(o.Apps, r.App) {
+			filtered = append(filtered, r)
+		}
+	}
+	for _, app := range o.Apps {
+		if !containsReleaseApp(fromReleases, app) {
+			return fmt.Errorf("app %s is not in environment %s", app, fromEnv.Key)
+		}
+	}
+
+	o.Function = func() error {
+		dir := o.OutDir
+		apps, err := o.syncApps(dir, toEnv, filtered)
+		if err != nil {
+			return err
+		}
+		promoteConfig, _, err := promoteconfig.Discover(dir, EnvironmentNamespace(toEnv))
+		if err != nil {
+			return fmt.Errorf("failed to discover the PromoteConfig in dir %s: %w", dir, err)
+		}
+		for _, app := range apps {
+			r, err := o.newPromoteRule(dir, promoteConfig, app.ManifestApp, true)
+			if err != nil {
+				return err
+			}
+			err = applyPromoteRule(r)
+			if err != nil {
+				return fmt.Errorf("failed to synchronise %s to %s: %w", app.App, toEnv.Key, err)
+			}
+		}
+		o.SyncedApps = apps
+		o.CommitTitle = fmt.Sprintf("chore: sync %d apps from %s to %s", len(o.SyncedApps), fromEnv.Key, toEnv.Key)
+		o.CommitMessage = SyncTable(o.SyncedApps, fromEnv.Key, toEnv.Key)
+		for _, note := range []string{o.freezeNote, o.policyNote} {
+			if note != "" {
+				o.CommitMessage += "\n\n" + note
+			}
+		}
+		return o.checkLock()
+	}
+
+	if o.EnvDir != "" {
+		err = o.PromoteInDir(o.EnvDir)
+	} else {
+		var gitURL string
+		gitURL, err = o.environmentGitURL(toEnv)
+		if err != nil {
+			return err
+		}
+		labels := []string{LabelSync, "env/" + toEnv.Key}
+		var draft bool
+		draft, err = o.checkSyncGates(toEnv, gitURL, filtered)
+		if err != nil {
+			return err
+		}
+		if draft {
+			labels = append(labels, "do-not-merge/hold")
+		}
+		err = o.acquireLock([]*jxcore.EnvironmentConfig{toEnv})
+		if err != nil {
+			return err
+		}
+		defer o.releaseLock()
+
+		var pr *scm.PullRequest
+		pr, err = o.Create(gitURL, "", labels, false)
+		if pr != nil {
+			log.Logger().Infof("created Pull Request %s", termcolor.ColorInfo(pr.Link))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(o.SyncedApps) == 0 {
+		log.Logger().Infof("environment %s is already in sync with %s", termcolor.ColorInfo(toEnv.Key), termcolor.ColorInfo(fromEnv.Key))
+	}
+	return nil
+}
+
+// syncApps returns the apps from the source environment which are missing or at a different version in the target
+// environment repository in the given dir
+func (o *SyncOptions) syncApps(dir string, toEnv *jxcore.EnvironmentConfig, fromReleases []*rules.Release) ([]SyncApp, error) {
+	toReleases, err := o.environmentReleases(dir, toEnv)
+	if err != nil {
+		return nil, err
+	}
+	var answer []SyncApp
+	for _, from := range fromReleases {
+		previousVersion := ""
+		for _, to := range toReleases {
+			if to.App == from.App {
+				previousVersion = to.Version
+				break
+			}
+		}
+		if previousVersion == from.Version {
+			continue
+		}
+		answer = append(answer, SyncApp{
+			ManifestApp: ManifestApp{
+				App:         from.App,
+				Version:     from.Version,
+				ReleaseName: from.ReleaseName,
+				HelmRepoURL: from.HelmRepositoryURL,
+			},
+			PreviousVersion: previousVersion,
+		})
+	}
+	return answer, nil
+}
+
+// checkSyncGates checks the promotion windows, change freezes and policies of the target environment against the apps
+// which need synchronising. Returns true if a draft Pull Request should be created or an error if it is not allowed
+func (o *SyncOptions) checkSyncGates(toEnv *jxcore.EnvironmentConfig, gitURL string, fromReleases []*rules.Release) (bool, error) {
+	toDir, err := o.cloneEnvironment(gitURL)
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(toDir) //nolint:errcheck
+
+	apps, err := o.syncApps(toDir, toEnv, fromReleases)
+	if err != nil {
+		return false, err
+	}
+	if len(apps) == 0 {
+		return false, nil
+	}
+	envs := []*jxcore.EnvironmentConfig{toEnv}
+	releaseInfo := &ReleaseInfo{Environments: envs}
+	for _, app := range apps {
+		releaseInfo.Apps = append(releaseInfo.Apps, app.ManifestApp)
+	}
+	now := time.Now()
+	frozenDraft, err := o.CheckFreeze(envs, now)
+	if err != nil {
+		return false, err
+	}
+	policyDraft, err := o.EvaluatePolicies(envs, releaseInfo, now)
+	if err != nil {
+		return false, err
+	}
+	return frozenDraft || policyDraft, nil
+}
+
+// SyncTable returns a markdown table of the apps being synchronised with their versions in each environment
+func SyncTable(apps []SyncApp, from, to string) string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "| App | %s | %s |\n", to, from)
+	buf.WriteString("| --- | --- | --- |\n")
+	for _, app := range apps {
+		previous := app.PreviousVersion
+		if previous == "" {
+			previous = "-"
+		}
+		fmt.Fprintf(buf, "| %s | %s | %s |\n", app.App, previous, app.Version)
+	}
+	return buf.String()
+}
+
+// environmentReleases returns the details of all the apps currently in the environment repository in the given dir
+// using the promote rule of the environment
+func (o *Options) environmentReleases(dir string, env *jxcore.EnvironmentConfig) ([]*rules.Release, error) {
+	promoteConfig, _, err := promoteconfig.Discover(dir, EnvironmentNamespace(env))
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the PromoteConfig in dir %s: %w", dir, err)
+	}
+	r := &rules.PromoteRule{
+		TemplateContext: rules.TemplateContext{
+			Namespace: o.Namespace,
+		},
+		Dir:           dir,
+		Config:        *promoteConfig,
+		DevEnvContext: &o.DevEnvContext,
+	}
+	releasesFn := factory.NewReleasesFunction(r)
+	if releasesFn == nil {
+		return nil, fmt.Errorf("cannot find the apps in environment %s as its promote rule does not support it", env.Key)
+	}
+	releases, err := releasesFn(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the apps in %s: %w", env.Key, err)
+	}
+	return releases, nil
+}
+
+func containsReleaseApp(releases []*rules.Release, app string) bool {
+	for _, r := range releases {
+		if r.App == app {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package promote
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/telemetry"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// traceCommand invokes the command inside a root span, exporting the traces and metrics via OTLP if the
+// OTEL_EXPORTER_OTLP_ENDPOINT environment variable is specified
+func (o *Options) traceCommand(name string, fn func() error) error {
+	shutdown := setupTelemetry()
+	defer shutdown()
+	return o.traceSpan(name, fn)
+}
+
+// traceSpan invokes the function inside a span using the telemetry which is already set up
+func (o *Options) traceSpan(name string, fn func() error) error {
+	end := o.StartSpan(name)
+	err := fn()
+	end(err)
+	return err
+}
+
+// setupTelemetry registers the global OTLP providers returning a function to export the telemetry and shut them down
+func setupTelemetry() func() {
+	shutdown, err := telemetry.Setup(context.Background())
+	if err != nil {
+		log.Logger().Warnf("failed to setup OpenTelemetry: %s", err.Error())
+	}
+	return func() {
+		err := shutdown(context.Background())
+		if err != nil {
+			log.Logger().Warnf("failed to export telemetry: %s", err.Error())
+		}
+	}
+}
+
+// lazyLoad lazy loads the development environment which may clone the dev environment repository and version stream
+func (o *Options) lazyLoad() error {
+	end := o.StartSpan("lazy load")
+	err := o.DevEnvContext.LazyLoad(o.GitClient, o.JXClient, o.Namespace, o.Git(), o.Dir)
+	end(err)
+	return err
+}
+
+// spanAttributes returns the span attributes for promoting the apps to the environments
+func (o *Options) spanAttributes(envs []*jxcore.EnvironmentConfig) []attribute.KeyValue {
+	var envNames, appNames []string
+	for _, env := range envs {
+		envNames = append(envNames, env.Key)
+	}
+	version := o.Version
+	apps := o.PromoteApps()
+	for _, app := range apps {
+		appNames = append(appNames, app.App)
+	}
+	if len(apps) == 1 {
+		version = apps[0].Version
+	}
+	return telemetry.PromoteAttributes(strings.Join(envNames, ", "), strings.Join(appNames, ", "), version)
+}
+
+// recordDeployment records the deployment frequency and lead time metrics for the promoted apps
+func (o *Options) recordDeployment(env *jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo, success bool) {
+	if !telemetry.Enabled() {
+		return
+	}
+	commitTime := o.releaseCommitTime()
+	ctx := o.TraceContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	apps := releaseInfo.Apps
+	if len(apps) == 0 {
+		apps = []ManifestApp{{App: o.promotedApp(), Version: releaseInfo.Version}}
+	}
+	for _, app := range apps {
+		telemetry.RecordDeployment(ctx, env.Key, app.App, app.Version, commitTime, success)
+	}
+}
+
+// releaseCommitTime returns the time of the latest commit in the source directory so that the lead time of the
+// release can be measured. A zero time is returned when promoting from a manifest or ignoring local files
+func (o *Options) releaseCommitTime() time.Time {
+	if o.IgnoreLocalFiles || len(o.ManifestApps) > 0 {
+		return time.Time{}
+	}
+	text, err := o.Git().Command(o.Dir, "log", "-1", "--format=%cI")
+	if err != nil {
+		log.Logger().Debugf("failed to find the time of the latest commit in dir %s: %s", o.Dir, err.Error())
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(text))
+	if err != nil {
+		log.Logger().Debugf("failed to parse the time of the latest commit %s: %s", text, err.Error())
+		return time.Time{}
+	}
+	return t
+}
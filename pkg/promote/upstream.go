@@ -0,0 +1,127 @@
+package promote
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/rules"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/rules/factory"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// CheckUpstreams checks that the versions of the apps being promoted are already in the upstream environments
+// configured for each environment in the development environment configuration. Returns an error if an app is
+// missing from an upstream environment or is at a different version
+func (o *Options) CheckUpstreams(envs []*jxcore.EnvironmentConfig, releaseInfo *ReleaseInfo) error {
+	dirs := map[string]string{}
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir) //nolint:errcheck
+		}
+	}()
+
+	for _, env := range envs {
+		envConfig := promoteconfig.FindEnvironment(o.DevPromoteConfig, env.Key)
+		if envConfig == nil {
+			continue
+		}
+		for _, upstream := range envConfig.Upstreams {
+			upstreamEnv := o.findEnvironment(upstream)
+			if upstreamEnv == nil {
+				return fmt.Errorf("could not find upstream environment %s of environment %s", upstream, env.Key)
+			}
+			gitURL, err := o.environmentGitURL(upstreamEnv)
+			if err != nil {
+				return fmt.Errorf("failed to find the git URL of upstream environment %s: %w", upstream, err)
+			}
+			dir := dirs[gitURL]
+			if dir == "" {
+				dir, err = o.cloneEnvironment(gitURL)
+				if err != nil {
+					return err
+				}
+				dirs[gitURL] = dir
+			}
+
+			for _, app := range releaseInfo.Apps {
+				if app.Version == "" {
+					log.Logger().Warnf("cannot check the version of %s in upstream environment %s as no version is being promoted", app.App, upstream)
+					continue
+				}
+				release, err := o.environmentRelease(dir, upstreamEnv, app)
+				if err != nil {
+					return err
+				}
+				version := ""
+				if release != nil {
+					version = release.Version
+				}
+				if version != app.Version {
+					if version == "" {
+						return fmt.Errorf("cannot promote %s version %s to environment %s as it is not in upstream environment %s", app.App, app.Version, env.Key, upstream)
+					}
+					return fmt.Errorf("cannot promote %s version %s to environment %s as upstream environment %s has version %s", app.App, app.Version, env.Key, upstream, version)
+				}
+				log.Logger().Infof("upstream environment %s has %s version %s", termcolor.ColorInfo(upstream), app.App, termcolor.ColorInfo(version))
+			}
+		}
+	}
+	return nil
+}
+
+// findEnvironment finds the environment with the given name in the requirements
+func (o *Options) findEnvironment(name string) *jxcore.EnvironmentConfig {
+	if o.DevEnvContext.Requirements == nil {
+		return nil
+	}
+	envs := o.DevEnvContext.Requirements.Environments
+	for i := range envs {
+		if envs[i].Key == name {
+			return &envs[i]
+		}
+	}
+	return nil
+}
+
+// cloneEnvironment clones the environment git repository into a temporary directory
+func (o *Options) cloneEnvironment(gitURL string) (string, error) {
+	cloneURL := gitURL
+	if o.ScmClientFactory.GitToken != "" && o.ScmClientFactory.GitUsername != "" {
+		var err error
+		cloneURL, err = o.ScmClientFactory.CreateAuthenticatedURL(gitURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to create authenticated git URL to clone with for private repositories: %w", err)
+		}
+	}
+	dir, err := gitclient.CloneToDir(o.Git(), cloneURL, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to clone environment git URL %s: %w", gitURL, err)
+	}
+	return dir, nil
+}
+
+// environmentRelease returns the details of the app currently in the environment repository in the given dir using
+// the promote rule of the environment or nil if the app is not in the environment
+func (o *Options) environmentRelease(dir string, env *jxcore.EnvironmentConfig, app ManifestApp) (*rules.Release, error) {
+	promoteConfig, _, err := promoteconfig.Discover(dir, EnvironmentNamespace(env))
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the PromoteConfig in dir %s: %w", dir, err)
+	}
+	r, err := o.newPromoteRule(dir, promoteConfig, app, false)
+	if err != nil {
+		return nil, err
+	}
+	releaseFn := factory.NewReleaseFunction(r)
+	if releaseFn == nil {
+		return nil, fmt.Errorf("cannot find the version of %s in environment %s as its promote rule does not support it", app.App, env.Key)
+	}
+	release, err := releaseFn(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the current version of %s in %s: %w", app.App, env.Key, err)
+	}
+	return release, nil
+}
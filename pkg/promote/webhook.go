@@ -0,0 +1,63 @@
+package promote
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/webhook"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	optionWebhookListen   = "webhook-listen"
+	optionWebhookPollTime = "webhook-poll-time"
+	optionWebhookSecret   = "webhook-secret"
+)
+
+// startWebhookListener starts listening for the webhooks of the promotion Pull Requests if --webhook-listen is specified
+// so that we can wait for them to merge without polling. Returns a function to stop listening
+func (o *Options) startWebhookListener() (func(), error) {
+	if o.WebhookListen == "" || o.NoPoll {
+		return func() {}, nil
+	}
+	o.webhooks = &webhook.Listener{
+		Addr:   o.WebhookListen,
+		Secret: o.WebhookSecret,
+	}
+	err := o.webhooks.Start()
+	if err != nil {
+		o.webhooks = nil
+		return nil, err
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := o.webhooks.Stop(ctx)
+		if err != nil {
+			log.Logger().Warnf("failed to stop listening for webhooks: %s", err.Error())
+		}
+		o.webhooks = nil
+	}, nil
+}
+
+// validateWebhookOptions checks there is a secret to validate the webhooks with and parses the fallback poll time
+// used when listening for webhooks
+func (o *Options) validateWebhookOptions() error {
+	if o.WebhookListen == "" {
+		return nil
+	}
+	if o.WebhookSecret == "" {
+		// otherwise anyone who can reach the listener could trigger polls with forged webhooks
+		return fmt.Errorf("--%s requires the --%s option or the $HMAC_TOKEN environment variable to validate the webhooks", optionWebhookListen, optionWebhookSecret)
+	}
+	if o.WebhookPollTime == "" {
+		return nil
+	}
+	duration, err := time.ParseDuration(o.WebhookPollTime)
+	if err != nil {
+		return fmt.Errorf("invalid duration format %s for option --%s: %w", o.WebhookPollTime, optionWebhookPollTime, err)
+	}
+	o.webhookPollDuration = &duration
+	return nil
+}
@@ -15,15 +15,23 @@ import (
 // Discover discovers the promote configuration.
 //
 // if an explicit configuration is found (in a current or parent directory of '.jx/promote.yaml' then that is used.
-// otherwise the env/Chart.yaml or 'jx-apps.yaml' are detected
+// otherwise the env/Chart.yaml or 'jx-apps.yaml' are detected. If the explicit configuration has no rule, such as
+// when it only has environment settings, the rule is detected too
 func Discover(dir, promoteNamespace string) (*v1alpha1.Promote, string, error) {
 	config, fileName, err := LoadPromote(dir, false)
 	if err != nil {
 		return config, fileName, fmt.Errorf("failed to load Promote configuration from %s: %w", dir, err)
 	}
-	if config != nil {
+	if config != nil && hasRule(&config.Spec) {
 		return config, fileName, nil
 	}
+	if config == nil {
+		config = &v1alpha1.Promote{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "generated",
+			},
+		}
+	}
 
 	envChart := filepath.Join(dir, "env", "Chart.yaml")
 	exists, err := files.FileExists(envChart)
@@ -31,35 +39,25 @@ func Discover(dir, promoteNamespace string) (*v1alpha1.Promote, string, error) {
 		return nil, "", fmt.Errorf("failed to check if file exists %s: %w", envChart, err)
 	}
 	if exists {
-		config := v1alpha1.Promote{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: "generated",
-			},
-			Spec: v1alpha1.PromoteSpec{
-				HelmRule: &v1alpha1.HelmRule{
-					Path: "env",
-				},
-			},
+		config.Spec.HelmRule = &v1alpha1.HelmRule{
+			Path: "env",
 		}
-		return &config, "", nil
+		return config, fileName, nil
 	}
 
 	path, err := findHelmfile(dir, promoteNamespace)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to find helmfile: %w", err)
 	}
-	config = &v1alpha1.Promote{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "generated",
-		},
-		Spec: v1alpha1.PromoteSpec{
-			HelmfileRule: &v1alpha1.HelmfileRule{
-				Path:      path,
-				Namespace: promoteNamespace,
-			},
-		},
+	config.Spec.HelmfileRule = &v1alpha1.HelmfileRule{
+		Path:      path,
+		Namespace: promoteNamespace,
 	}
-	return config, "", nil
+	return config, fileName, nil
+}
+
+func hasRule(spec *v1alpha1.PromoteSpec) bool {
+	return spec.FileRule != nil || spec.HelmRule != nil || spec.HelmfileRule != nil || spec.KptRule != nil
 }
 
 func findHelmfile(dir, promoteNamespace string) (string, error) {
@@ -105,6 +103,23 @@ func LoadPromote(dir string, failIfMissing bool) (*v1alpha1.Promote, string, err
 	return nil, "", nil
 }
 
+// LoadDevPromote loads the '.jx/promote.yaml' file from the development environment git repository in the given
+// dir. Returns nil if the dir is blank or there is no file
+func LoadDevPromote(dir string) (*v1alpha1.Promote, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	fileName := filepath.Join(dir, ".jx", "promote.yaml")
+	exists, err := files.FileExists(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if file exists %s: %w", fileName, err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	return LoadPromoteFile(fileName)
+}
+
 // LoadPromoteFile loads a specific boot config YAML file
 func LoadPromoteFile(fileName string) (*v1alpha1.Promote, error) {
 	config := &v1alpha1.Promote{}
@@ -121,3 +136,59 @@ func LoadPromoteFile(fileName string) (*v1alpha1.Promote, error) {
 
 	return config, nil
 }
+
+// FindEnvironment finds the settings for the given environment name in the promote configuration. If there are
+// no settings for the environment then the default settings with a blank name are returned or nil if there are none
+func FindEnvironment(config *v1alpha1.Promote, name string) *v1alpha1.PromoteEnvironment {
+	if config == nil {
+		return nil
+	}
+	var answer *v1alpha1.PromoteEnvironment
+	for i := range config.Spec.Environments {
+		env := &config.Spec.Environments[i]
+		if env.Name == name {
+			return env
+		}
+		if env.Name == "" && answer == nil {
+			answer = env
+		}
+	}
+	return answer
+}
+
+// FindAppStatus finds the status of the last promotion of the app to the given environment or nil if there is none
+func FindAppStatus(status *v1alpha1.PromoteStatus, envName, app string) *v1alpha1.PromoteAppStatus {
+	for i := range status.Environments {
+		env := &status.Environments[i]
+		if env.Name != envName {
+			continue
+		}
+		for j := range env.Apps {
+			if env.Apps[j].Name == app {
+				return &env.Apps[j]
+			}
+		}
+	}
+	return nil
+}
+
+// SetAppStatus sets the status of the last promotion of the app to the given environment replacing any previous
+// promotion of the app
+func SetAppStatus(status *v1alpha1.PromoteStatus, envName string, app *v1alpha1.PromoteAppStatus) {
+	existing := FindAppStatus(status, envName, app.Name)
+	if existing != nil {
+		*existing = *app
+		return
+	}
+	for i := range status.Environments {
+		env := &status.Environments[i]
+		if env.Name == envName {
+			env.Apps = append(env.Apps, *app)
+			return
+		}
+	}
+	status.Environments = append(status.Environments, v1alpha1.PromoteEnvironmentStatus{
+		Name: envName,
+		Apps: []v1alpha1.PromoteAppStatus{*app},
+	})
+}
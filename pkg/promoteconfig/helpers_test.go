@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/jenkins-x-plugins/jx-promote/pkg/apis/promote/v1alpha1"
 	"github.com/jenkins-x-plugins/jx-promote/pkg/promoteconfig"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -52,3 +53,70 @@ func TestDiscoverPromoteConfigHelmfile(t *testing.T) {
 
 	t.Logf("discovered config %#v for dir %s", cfg, dir)
 }
+
+func TestDiscoverPromoteConfigSettingsOnly(t *testing.T) {
+	dir := filepath.Join("test_data", "settings")
+	cfg, fileName, err := promoteconfig.Discover(dir, testPromoteNS)
+	require.NoError(t, err, "for dir %s", dir)
+	require.NotNil(t, cfg, "config not returned for %s", dir)
+	assert.NotEmpty(t, fileName, "fileName for %s", dir)
+
+	require.NotNil(t, cfg.Spec.HelmfileRule, "cfg.Spec.HelmfileRule for %s", dir)
+	assert.Equal(t, "helmfile.yaml", cfg.Spec.HelmfileRule.Path, "cfg.Spec.HelmfileRule.Path for %s", dir)
+
+	env := promoteconfig.FindEnvironment(cfg, "staging")
+	require.NotNil(t, env, "should find staging for %s", dir)
+	assert.True(t, env.DirectPush, "staging DirectPush for %s", dir)
+
+	env = promoteconfig.FindEnvironment(cfg, "production")
+	require.NotNil(t, env, "should find production for %s", dir)
+	require.Len(t, env.SmokeChecks, 1, "production SmokeChecks for %s", dir)
+	assert.Equal(t, "health", env.SmokeChecks[0].Name, "production SmokeChecks name for %s", dir)
+}
+
+func TestFindEnvironment(t *testing.T) {
+	cfg := &v1alpha1.Promote{
+		Spec: v1alpha1.PromoteSpec{
+			Environments: []v1alpha1.PromoteEnvironment{
+				{
+					DirectPush: true,
+				},
+				{
+					Name: "production",
+				},
+			},
+		},
+	}
+
+	env := promoteconfig.FindEnvironment(cfg, "production")
+	require.NotNil(t, env, "should find production")
+	assert.False(t, env.DirectPush, "production DirectPush")
+
+	env = promoteconfig.FindEnvironment(cfg, "staging")
+	require.NotNil(t, env, "should find the default for staging")
+	assert.True(t, env.DirectPush, "staging DirectPush")
+
+	assert.Nil(t, promoteconfig.FindEnvironment(&v1alpha1.Promote{}, "staging"), "no environments configured")
+}
+
+func TestSetAppStatus(t *testing.T) {
+	status := &v1alpha1.PromoteStatus{}
+	promoteconfig.SetAppStatus(status, "staging", &v1alpha1.PromoteAppStatus{Name: "myapp", Version: "1.0.0"})
+	promoteconfig.SetAppStatus(status, "staging", &v1alpha1.PromoteAppStatus{Name: "another", Version: "2.0.0"})
+	promoteconfig.SetAppStatus(status, "production", &v1alpha1.PromoteAppStatus{Name: "myapp", Version: "0.9.0"})
+	promoteconfig.SetAppStatus(status, "staging", &v1alpha1.PromoteAppStatus{Name: "myapp", Version: "1.1.0", State: "merged"})
+
+	require.Len(t, status.Environments, 2, "environments")
+	assert.Len(t, status.Environments[0].Apps, 2, "staging apps")
+
+	app := promoteconfig.FindAppStatus(status, "staging", "myapp")
+	require.NotNil(t, app, "should find myapp in staging")
+	assert.Equal(t, "1.1.0", app.Version, "staging version")
+	assert.Equal(t, "merged", app.State, "staging state")
+
+	app = promoteconfig.FindAppStatus(status, "production", "myapp")
+	require.NotNil(t, app, "should find myapp in production")
+	assert.Equal(t, "0.9.0", app.Version, "production version")
+
+	assert.Nil(t, promoteconfig.FindAppStatus(status, "production", "another"), "another not promoted to production")
+}
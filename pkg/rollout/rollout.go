@@ -0,0 +1,248 @@
+package rollout
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// LabelInstance the standard label used by helm charts for the release name
+	LabelInstance = "app.kubernetes.io/instance"
+
+	// LabelRelease the label used by older helm charts for the release name
+	LabelRelease = "release"
+
+	// HelmStatusDeployed the status of a successfully deployed helm release
+	HelmStatusDeployed = "deployed"
+)
+
+// Result the result of checking the rollout of a release
+type Result struct {
+	// Ready true if the release is at the expected version and all of its workloads are rolled out and ready
+	Ready bool
+
+	// Pending descriptions of the things which are not yet ready
+	Pending []string
+}
+
+// HelmRelease the details of a helm release stored in a secret
+type HelmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status string `json:"status"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// Check checks if the helm release in the namespace is deployed at the given version (if specified) and that its
+// Deployments and StatefulSets have finished rolling out and their pods are ready
+func Check(ctx context.Context, kubeClient kubernetes.Interface, ns, releaseName, version string) (*Result, error) {
+	result := &Result{}
+
+	release, err := FindHelmRelease(ctx, kubeClient, ns, releaseName)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case release == nil:
+		result.Pending = append(result.Pending, fmt.Sprintf("helm release %s not found", releaseName))
+	case release.Info.Status != HelmStatusDeployed:
+		result.Pending = append(result.Pending, fmt.Sprintf("helm release %s revision %d is %s", releaseName, release.Version, release.Info.Status))
+	case version != "" && release.Chart.Metadata.Version != version:
+		result.Pending = append(result.Pending, fmt.Sprintf("helm release %s is at version %s not %s", releaseName, release.Chart.Metadata.Version, version))
+	}
+
+	for _, selector := range releaseSelectors(releaseName) {
+		listOptions := metav1.ListOptions{LabelSelector: selector}
+		deployments, err := kubeClient.AppsV1().Deployments(ns).List(ctx, listOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Deployments in namespace %s with selector %s: %w", ns, selector, err)
+		}
+		for i := range deployments.Items {
+			d := &deployments.Items[i]
+			if msg := deploymentPending(d); msg != "" {
+				result.Pending = append(result.Pending, msg)
+			}
+		}
+
+		statefulSets, err := kubeClient.AppsV1().StatefulSets(ns).List(ctx, listOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list StatefulSets in namespace %s with selector %s: %w", ns, selector, err)
+		}
+		for i := range statefulSets.Items {
+			s := &statefulSets.Items[i]
+			if msg := statefulSetPending(s); msg != "" {
+				result.Pending = append(result.Pending, msg)
+			}
+		}
+
+		pods, err := kubeClient.CoreV1().Pods(ns).List(ctx, listOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Pods in namespace %s with selector %s: %w", ns, selector, err)
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if !podReady(pod) {
+				result.Pending = append(result.Pending, fmt.Sprintf("pod %s is not ready", pod.Name))
+			}
+		}
+	}
+	result.Ready = len(result.Pending) == 0
+	return result, nil
+}
+
+// FindHelmRelease finds the latest revision of the helm release in the namespace or returns nil if it does not exist
+func FindHelmRelease(ctx context.Context, kubeClient kubernetes.Interface, ns, releaseName string) (*HelmRelease, error) {
+	secrets, err := kubeClient.CoreV1().Secrets(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: "owner=helm,name=" + releaseName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list helm release secrets in namespace %s: %w", ns, err)
+	}
+	items := secrets.Items
+	if len(items) == 0 {
+		return nil, nil
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return revision(&items[i]) > revision(&items[j])
+	})
+	secret := &items[0]
+	release, err := DecodeHelmRelease(secret.Data["release"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode helm release secret %s in namespace %s: %w", secret.Name, ns, err)
+	}
+	return release, nil
+}
+
+// DecodeHelmRelease decodes the release data stored by helm in a secret
+func DecodeHelmRelease(data []byte) (*HelmRelease, error) {
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to base64 decode release: %w", err)
+	}
+	if len(decoded) > 2 && decoded[0] == 0x1f && decoded[1] == 0x8b {
+		r, err := gzip.NewReader(bytes.NewReader(decoded))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		defer r.Close() //nolint:errcheck
+		decoded, err = io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress release: %w", err)
+		}
+	}
+	release := &HelmRelease{}
+	err = json.Unmarshal(decoded, release)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal release: %w", err)
+	}
+	return release, nil
+}
+
+// EncodeHelmRelease encodes the release in the same way helm stores it in a secret
+func EncodeHelmRelease(release *HelmRelease) ([]byte, error) {
+	data, err := json.Marshal(release)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal release: %w", err)
+	}
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err = w.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress release: %w", err)
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress release: %w", err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+func releaseSelectors(releaseName string) []string {
+	return []string{
+		LabelInstance + "=" + releaseName,
+		LabelRelease + "=" + releaseName + "," + LabelInstance + "!=" + releaseName,
+	}
+}
+
+func revision(secret *corev1.Secret) int {
+	v, err := strconv.Atoi(secret.Labels["version"])
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+func deploymentPending(d *appsv1.Deployment) string {
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	s := d.Status
+	switch {
+	case s.ObservedGeneration < d.Generation:
+		return fmt.Sprintf("deployment %s has not observed the latest generation", d.Name)
+	case s.UpdatedReplicas < replicas:
+		return fmt.Sprintf("deployment %s has %d of %d replicas updated", d.Name, s.UpdatedReplicas, replicas)
+	case s.Replicas > s.UpdatedReplicas:
+		return fmt.Sprintf("deployment %s has %d old replicas pending termination", d.Name, s.Replicas-s.UpdatedReplicas)
+	case s.AvailableReplicas < s.UpdatedReplicas:
+		return fmt.Sprintf("deployment %s has %d of %d updated replicas available", d.Name, s.AvailableReplicas, s.UpdatedReplicas)
+	}
+	return ""
+}
+
+func statefulSetPending(s *appsv1.StatefulSet) string {
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+	st := s.Status
+	switch {
+	case st.ObservedGeneration < s.Generation:
+		return fmt.Sprintf("statefulset %s has not observed the latest generation", s.Name)
+	case st.UpdatedReplicas < replicas:
+		return fmt.Sprintf("statefulset %s has %d of %d replicas updated", s.Name, st.UpdatedReplicas, replicas)
+	case st.ReadyReplicas < replicas:
+		return fmt.Sprintf("statefulset %s has %d of %d replicas ready", s.Name, st.ReadyReplicas, replicas)
+	case st.UpdateRevision != "" && st.CurrentRevision != st.UpdateRevision:
+		return fmt.Sprintf("statefulset %s is updating to revision %s", s.Name, st.UpdateRevision)
+	}
+	return ""
+}
+
+func podReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase == corev1.PodSucceeded {
+		// lets ignore completed or terminating pods
+		return true
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+//go:build unit
+// +build unit
+
+package rollout_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/rollout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	ns          = "jx-staging"
+	releaseName = "myapp"
+)
+
+func TestCheck(t *testing.T) {
+	replicas := int32(2)
+	labels := map[string]string{rollout.LabelInstance: releaseName}
+
+	readyDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: releaseName, Namespace: ns, Labels: labels, Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 2,
+			Replicas:           2,
+			UpdatedReplicas:    2,
+			AvailableReplicas:  2,
+		},
+	}
+	rollingDeployment := readyDeployment.DeepCopy()
+	rollingDeployment.Status.UpdatedReplicas = 1
+	rollingDeployment.Status.Replicas = 3
+
+	readyPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: releaseName + "-abc", Namespace: ns, Labels: labels},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	notReadyPod := readyPod.DeepCopy()
+	notReadyPod.Status.Conditions[0].Status = corev1.ConditionFalse
+
+	testCases := []struct {
+		name    string
+		objects []runtime.Object
+		version string
+		ready   bool
+		pending []string
+	}{
+		{
+			name:    "ready",
+			objects: []runtime.Object{helmSecret(t, 1, "1.0.0", "superseded"), helmSecret(t, 2, "1.2.3", "deployed"), readyDeployment, readyPod},
+			version: "1.2.3",
+			ready:   true,
+		},
+		{
+			name:    "missing release",
+			objects: []runtime.Object{readyDeployment, readyPod},
+			version: "1.2.3",
+			pending: []string{"helm release myapp not found"},
+		},
+		{
+			name:    "old version",
+			objects: []runtime.Object{helmSecret(t, 1, "1.0.0", "deployed"), readyDeployment, readyPod},
+			version: "1.2.3",
+			pending: []string{"helm release myapp is at version 1.0.0 not 1.2.3"},
+		},
+		{
+			name:    "rolling out",
+			objects: []runtime.Object{helmSecret(t, 2, "1.2.3", "deployed"), rollingDeployment, notReadyPod},
+			version: "1.2.3",
+			pending: []string{"deployment myapp has 1 of 2 replicas updated", "pod myapp-abc is not ready"},
+		},
+	}
+
+	for _, tc := range testCases {
+		kubeClient := fake.NewSimpleClientset(tc.objects...)
+		result, err := rollout.Check(context.TODO(), kubeClient, ns, releaseName, tc.version)
+		require.NoError(t, err, "for %s", tc.name)
+		assert.Equal(t, tc.ready, result.Ready, "ready for %s", tc.name)
+		assert.Equal(t, tc.pending, result.Pending, "pending for %s", tc.name)
+	}
+}
+
+func helmSecret(t *testing.T, revision int, version, status string) *corev1.Secret {
+	release := &rollout.HelmRelease{
+		Name:      releaseName,
+		Namespace: ns,
+		Version:   revision,
+	}
+	release.Info.Status = status
+	release.Chart.Metadata.Name = releaseName
+	release.Chart.Metadata.Version = version
+
+	data, err := rollout.EncodeHelmRelease(release)
+	require.NoError(t, err, "failed to encode release")
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sh.helm.release.v1." + releaseName + ".v" + strconv.Itoa(revision),
+			Namespace: ns,
+			Labels: map[string]string{
+				"owner":   "helm",
+				"name":    releaseName,
+				"status":  status,
+				"version": strconv.Itoa(revision),
+			},
+		},
+		Data: map[string][]byte{"release": data},
+	}
+}
@@ -25,3 +25,42 @@ func NewFunction(r *rules.PromoteRule) rules.RuleFunction {
 	}
 	return nil
 }
+
+// NewVersionFunction creates a function to find the current version of the app based on the kind of rule.
+// Returns nil if the kind of rule does not support finding the current version
+func NewVersionFunction(r *rules.PromoteRule) rules.VersionFunction {
+	spec := r.Config.Spec
+	if spec.HelmRule != nil {
+		return helm.Version
+	}
+	if spec.HelmfileRule != nil {
+		return helmfile.Version
+	}
+	return nil
+}
+
+// NewReleaseFunction creates a function to find the details of the app currently in the environment based on the
+// kind of rule. Returns nil if the kind of rule does not support finding the current release
+func NewReleaseFunction(r *rules.PromoteRule) rules.ReleaseFunction {
+	spec := r.Config.Spec
+	if spec.HelmRule != nil {
+		return helm.Release
+	}
+	if spec.HelmfileRule != nil {
+		return helmfile.Release
+	}
+	return nil
+}
+
+// NewReleasesFunction creates a function to find the details of all the apps currently in the environment based on
+// the kind of rule. Returns nil if the kind of rule does not support finding the current releases
+func NewReleasesFunction(r *rules.PromoteRule) rules.ReleasesFunction {
+	spec := r.Config.Spec
+	if spec.HelmRule != nil {
+		return helm.Releases
+	}
+	if spec.HelmfileRule != nil {
+		return helmfile.Releases
+	}
+	return nil
+}
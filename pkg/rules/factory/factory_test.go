@@ -84,6 +84,13 @@ func TestRuleFactory(t *testing.T) {
 
 		testhelpers.AssertTextFilesEqual(t, filepath.Join(src, fileName+".1.expected"), target, fileName)
 
+		versionFn := factory.NewVersionFunction(r)
+		if versionFn != nil {
+			version, err := versionFn(r)
+			require.NoError(t, err, "failed to find current version at dir %s", dir)
+			assert.Equal(t, r.Version, version, "current version at dir %s", dir)
+		}
+
 		// now lets modify to new version
 		r.Version = "1.2.4"
 
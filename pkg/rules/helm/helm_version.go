@@ -0,0 +1,73 @@
+package helm
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/helmer"
+)
+
+// Version returns the version of the app in the charts 'requirements.yaml' or an empty string if the app is not a
+// dependency of the chart
+func Version(r *rules.PromoteRule) (string, error) {
+	release, err := Release(r)
+	if err != nil || release == nil {
+		return "", err
+	}
+	return release.Version, nil
+}
+
+// Release returns the details of the app in the charts 'requirements.yaml' or nil if the app is not a dependency of
+// the chart
+func Release(r *rules.PromoteRule) (*rules.Release, error) {
+	releases, err := Releases(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, release := range releases {
+		if release.App == r.AppName {
+			return release, nil
+		}
+	}
+	return nil, nil
+}
+
+// Releases returns the details of all the dependencies in the charts 'requirements.yaml'
+func Releases(r *rules.PromoteRule) ([]*rules.Release, error) {
+	rule := r.Config.Spec.HelmRule
+	if rule == nil {
+		return nil, fmt.Errorf("no helmRule configured")
+	}
+	dir := r.Dir
+	if rule.Path != "" {
+		dir = filepath.Join(dir, rule.Path)
+	}
+	requirementsFile, err := helmer.FindRequirementsFileName(dir)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := files.FileExists(requirementsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect file %s: %w", requirementsFile, err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	requirements, err := helmer.LoadRequirementsFile(requirementsFile)
+	if err != nil {
+		return nil, err
+	}
+	var answer []*rules.Release
+	for _, dep := range requirements.Dependencies {
+		if dep != nil {
+			answer = append(answer, &rules.Release{
+				App:               dep.Name,
+				Version:           dep.Version,
+				HelmRepositoryURL: dep.Repository,
+			})
+		}
+	}
+	return answer, nil
+}
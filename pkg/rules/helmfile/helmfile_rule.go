@@ -123,31 +123,11 @@ func modifyHelmfileApps(r *rules.PromoteRule, helmStates []*state.HelmState, pro
 		return nil
 	}
 
-	// Time to use scoring instead of just a simple found.
 	var highestScorer *state.ReleaseSpec
-	highestScore := 0
-
 	if !keepOldReleases {
-		for _, helmfile := range helmStates {
-			for i := range helmfile.Releases {
-				score := 0
-				release := &helmfile.Releases[i]
-
-				if release.Name == r.AppName && (release.Namespace == promoteNs || isRemoteEnv) {
-					score++
-				}
-
-				if (r.ReleaseName != "" && release.Name == r.ReleaseName) && (release.Namespace == promoteNs || isRemoteEnv) {
-					// This scores higher as it's a direct match
-					score += 2
-				}
-
-				if score > highestScore {
-					highestScorer = release
-					highestScore = score
-				}
-			}
-		}
+		highestScorer = findRelease(r, helmStates, func(release *state.ReleaseSpec) bool {
+			return release.Namespace == promoteNs || isRemoteEnv
+		})
 	}
 
 	lastHelmState := helmStates[len(helmStates)-1]
@@ -174,32 +154,45 @@ func promoteNestedHelmfileReleases(r *rules.PromoteRule, details *envctx.ChartDe
 		}
 	}
 
-	// Time to use scoring instead of just a simple found.
 	var highestScorer *state.ReleaseSpec
-	highestScore := 0
 	if !keepOldReleases {
-		for _, helmfile := range helmStates {
-			for i := range helmfile.Releases {
-				score := 0
-				release := &helmfile.Releases[i]
+		highestScorer = findRelease(r, helmStates, func(*state.ReleaseSpec) bool {
+			return true
+		})
+	}
 
-				if release.Name == r.AppName {
-					score++
-				}
+	updateHelmState(r, details, promoteNs, highestScorer, lastHelmState, keepOldReleases)
+}
 
-				if r.ReleaseName != "" && release.Name == r.ReleaseName {
-					score += 2
-				}
+// findRelease finds the release for the app in the helm states which matches the namespace filter.
+// Time to use scoring instead of just a simple found.
+func findRelease(r *rules.PromoteRule, helmStates []*state.HelmState, matchNamespace func(*state.ReleaseSpec) bool) *state.ReleaseSpec {
+	var highestScorer *state.ReleaseSpec
+	highestScore := 0
+	for _, helmfile := range helmStates {
+		for i := range helmfile.Releases {
+			score := 0
+			release := &helmfile.Releases[i]
+			if !matchNamespace(release) {
+				continue
+			}
 
-				if score > highestScore {
-					highestScorer = release
-					highestScore = score
-				}
+			if release.Name == r.AppName {
+				score++
+			}
+
+			if r.ReleaseName != "" && release.Name == r.ReleaseName {
+				// This scores higher as it's a direct match
+				score += 2
+			}
+
+			if score > highestScore {
+				highestScorer = release
+				highestScore = score
 			}
 		}
 	}
-
-	updateHelmState(r, details, promoteNs, highestScorer, lastHelmState, keepOldReleases)
+	return highestScorer
 }
 
 func updateHelmState(r *rules.PromoteRule, details *envctx.ChartDetails, promoteNs string, foundRelease *state.ReleaseSpec, helmState *state.HelmState, keepOldReleases bool) {
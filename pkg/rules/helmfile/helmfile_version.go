@@ -0,0 +1,182 @@
+package helmfile
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x-plugins/jx-promote/pkg/rules"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+)
+
+// Version returns the version of the app in the helmfile or an empty string if the app is not in the helmfile
+func Version(r *rules.PromoteRule) (string, error) {
+	release, err := Release(r)
+	if err != nil || release == nil {
+		return "", err
+	}
+	return release.Version, nil
+}
+
+// Release returns the details of the app in the helmfile or nil if the app is not in the helmfile
+func Release(r *rules.PromoteRule) (*rules.Release, error) {
+	helmStates, matchNamespace, err := loadHelmStates(r)
+	if err != nil || len(helmStates) == 0 {
+		return nil, err
+	}
+
+	release := findRelease(r, helmStates, matchNamespace)
+	if release != nil {
+		return toRelease(helmStates, release), nil
+	}
+
+	// when keeping old releases the release names are suffixed with the version so lets find the latest chart version
+	var answer *state.ReleaseSpec
+	var answerSemVer *semver.Version
+	for _, helmState := range helmStates {
+		for i := range helmState.Releases {
+			release := &helmState.Releases[i]
+			if chartName(release.Chart) != r.AppName || !matchNamespace(release) {
+				continue
+			}
+			sv, err := semver.ParseTolerant(release.Version)
+			if err != nil {
+				continue
+			}
+			if answerSemVer == nil || sv.GT(*answerSemVer) {
+				answer = release
+				answerSemVer = &sv
+			}
+		}
+	}
+	if answer == nil {
+		return nil, nil
+	}
+	return toRelease(helmStates, answer), nil
+}
+
+// Releases returns the details of all the apps in the helmfile in the namespace being promoted to. If there are
+// multiple releases of a chart due to keeping old releases the latest version is returned
+func Releases(r *rules.PromoteRule) ([]*rules.Release, error) {
+	helmStates, matchNamespace, err := loadHelmStates(r)
+	if err != nil || len(helmStates) == 0 {
+		return nil, err
+	}
+
+	m := map[string]*rules.Release{}
+	for _, helmState := range helmStates {
+		for i := range helmState.Releases {
+			release := &helmState.Releases[i]
+			if !matchNamespace(release) {
+				continue
+			}
+			current := toRelease(helmStates, release)
+			existing := m[current.App]
+			if existing != nil && !newerVersion(current.Version, existing.Version) {
+				continue
+			}
+			m[current.App] = current
+		}
+	}
+
+	answer := make([]*rules.Release, 0, len(m))
+	for _, release := range m {
+		answer = append(answer, release)
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		return answer[i].App < answer[j].App
+	})
+	return answer, nil
+}
+
+// loadHelmStates loads the helmfiles of the rule along with a function to match releases in the namespace being
+// promoted to. Returns no helm states if the helmfile does not exist
+func loadHelmStates(r *rules.PromoteRule) ([]*state.HelmState, func(*state.ReleaseSpec) bool, error) {
+	rule := r.Config.Spec.HelmfileRule
+	if rule == nil {
+		return nil, nil, fmt.Errorf("no helmfileRule configured")
+	}
+	path := rule.Path
+	if path == "" {
+		path = "helmfile.yaml"
+	}
+	file := filepath.Join(r.Dir, path)
+	exists, err := files.FileExists(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to detect if file exists %s: %w", file, err)
+	}
+	if !exists {
+		return nil, nil, nil
+	}
+	helmStates, err := helmfiles.LoadHelmfile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load file %s: %w", file, err)
+	}
+
+	promoteNs := rule.Namespace
+	if promoteNs == "" {
+		promoteNs = r.Namespace
+		if promoteNs == "" {
+			promoteNs = "jx"
+		}
+	}
+	isRemoteEnv := r.DevEnvContext != nil && r.DevEnvContext.DevEnv != nil && r.DevEnvContext.DevEnv.Spec.RemoteCluster
+	dirName, _ := filepath.Split(path)
+	nestedHelmfile := dirName != ""
+
+	matchNamespace := func(release *state.ReleaseSpec) bool {
+		return nestedHelmfile || release.Namespace == promoteNs || isRemoteEnv
+	}
+	return helmStates, matchNamespace, nil
+}
+
+// toRelease converts the helmfile release to the release details resolving the chart repository URL from the
+// repositories in the helmfiles
+func toRelease(helmStates []*state.HelmState, release *state.ReleaseSpec) *rules.Release {
+	answer := &rules.Release{
+		App:     chartName(release.Chart),
+		Version: release.Version,
+
+		// lets remove the version suffix added to the release names when keeping old releases
+		ReleaseName: strings.TrimSuffix(release.Name, "-"+strings.ReplaceAll(release.Version, ".", "-")),
+	}
+	idx := strings.Index(release.Chart, "/")
+	if idx <= 0 {
+		return answer
+	}
+	repoName := release.Chart[:idx]
+	for _, helmState := range helmStates {
+		for i := range helmState.Repositories {
+			repo := &helmState.Repositories[i]
+			if repo.Name == repoName {
+				answer.HelmRepositoryURL = repo.URL
+				return answer
+			}
+		}
+	}
+	return answer
+}
+
+// chartName returns the name of the chart without the repository prefix
+func chartName(chart string) string {
+	idx := strings.LastIndex(chart, "/")
+	return chart[idx+1:]
+}
+
+// newerVersion returns true if the version is newer than the existing version
+func newerVersion(version, existing string) bool {
+	sv, err := semver.ParseTolerant(version)
+	if err != nil {
+		return false
+	}
+	esv, err := semver.ParseTolerant(existing)
+	if err != nil {
+		return true
+	}
+	return sv.GT(esv)
+}
@@ -28,3 +28,22 @@ type TemplateContext struct {
 
 // RuleFunction a rule function for evaluating the rule
 type RuleFunction func(*PromoteRule) error
+
+// VersionFunction a function for finding the version of the app currently in the environment.
+// An empty string is returned if the app is not yet in the environment
+type VersionFunction func(*PromoteRule) (string, error)
+
+// Release the details of the app currently in an environment
+type Release struct {
+	App               string
+	Version           string
+	HelmRepositoryURL string
+	ReleaseName       string
+}
+
+// ReleaseFunction a function for finding the details of the app currently in the environment.
+// Nil is returned if the app is not yet in the environment
+type ReleaseFunction func(*PromoteRule) (*Release, error)
+
+// ReleasesFunction a function for finding the details of all the apps currently in the environment
+type ReleasesFunction func(*PromoteRule) ([]*Release, error)
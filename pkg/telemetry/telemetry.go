@@ -0,0 +1,153 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// InstrumentationName the name of the tracer and meter used to instrument promotions
+	InstrumentationName = "github.com/jenkins-x-plugins/jx-promote"
+
+	// ServiceName the default service name of the telemetry
+	ServiceName = "jx-promote"
+
+	// AttributeEnvironment the environment being promoted to
+	AttributeEnvironment = attribute.Key("promote.environment")
+
+	// AttributeApp the application being promoted
+	AttributeApp = attribute.Key("promote.app")
+
+	// AttributeVersion the version being promoted
+	AttributeVersion = attribute.Key("promote.version")
+
+	// AttributeResult the result of a deployment: success or failure
+	AttributeResult = attribute.Key("promote.result")
+
+	// ResultSuccess the deployment succeeded
+	ResultSuccess = "success"
+
+	// ResultFailure the deployment failed
+	ResultFailure = "failure"
+
+	// MetricDeployments the counter of deployments used for the deployment frequency
+	MetricDeployments = "promote.deployments"
+
+	// MetricLeadTime the histogram of the time from the release commit to its deployment
+	MetricLeadTime = "promote.lead_time"
+)
+
+// Enabled returns true if an OTLP endpoint is configured via the standard OpenTelemetry environment variables
+func Enabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Setup registers the global OTLP trace and metric providers if an endpoint is configured.
+// The returned function flushes and shuts down the providers and should be invoked before exiting
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if !Enabled() {
+		return noop, nil
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return noop, fmt.Errorf("failed to create the telemetry resource: %w", err)
+	}
+
+	traceExporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return noop, fmt.Errorf("failed to create the OTLP trace exporter: %w", err)
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		return noop, fmt.Errorf("failed to create the OTLP metric exporter: %w", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter), sdktrace.WithResource(res))
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)), sdkmetric.WithResource(res))
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetMeterProvider(meterProvider)
+
+	return func(ctx context.Context) error {
+		return errors.Join(tracerProvider.Shutdown(ctx), meterProvider.Shutdown(ctx))
+	}, nil
+}
+
+// Tracer returns the tracer used to instrument promotions
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// End ends the span recording the error if it is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// PromoteAttributes returns the attributes of a promotion omitting any blank values
+func PromoteAttributes(env, app, version string) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if env != "" {
+		attrs = append(attrs, AttributeEnvironment.String(env))
+	}
+	if app != "" {
+		attrs = append(attrs, AttributeApp.String(app))
+	}
+	if version != "" {
+		attrs = append(attrs, AttributeVersion.String(version))
+	}
+	return attrs
+}
+
+// RecordDeployment records a deployment of the app to the environment for the DORA deployment frequency. If the
+// deployment succeeded and the commit time of the release is known the lead time is recorded too
+func RecordDeployment(ctx context.Context, env, app, version string, commitTime time.Time, success bool) {
+	meter := otel.Meter(InstrumentationName)
+	attrs := PromoteAttributes(env, app, version)
+	result := ResultSuccess
+	if !success {
+		result = ResultFailure
+	}
+
+	deployments, err := meter.Int64Counter(MetricDeployments, metric.WithDescription("The number of promotions deployed to an environment"))
+	if err == nil {
+		deployments.Add(ctx, 1, metric.WithAttributes(append(attrs, AttributeResult.String(result))...))
+	}
+	if !success || commitTime.IsZero() {
+		return
+	}
+	leadTime, err := meter.Float64Histogram(MetricLeadTime, metric.WithDescription("The time from the release commit to its deployment in an environment"), metric.WithUnit("s"))
+	if err == nil {
+		leadTime.Record(ctx, time.Since(commitTime).Seconds(), metric.WithAttributes(attrs...))
+	}
+}
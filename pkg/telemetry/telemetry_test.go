@@ -0,0 +1,66 @@
+//go:build unit
+// +build unit
+
+package telemetry_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupExportsTracesAndMetrics(t *testing.T) {
+	var lock sync.Mutex
+	paths := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		paths[r.URL.Path]++
+		lock.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+	require.True(t, telemetry.Enabled(), "telemetry should be enabled")
+
+	ctx := context.TODO()
+	shutdown, err := telemetry.Setup(ctx)
+	require.NoError(t, err, "failed to setup telemetry")
+
+	ctx, span := telemetry.Tracer().Start(ctx, "promote")
+	telemetry.RecordDeployment(ctx, "staging", "myapp", "1.2.3", time.Now().Add(-time.Hour), true)
+	telemetry.End(span, errors.New("failed"))
+
+	err = shutdown(context.TODO())
+	require.NoError(t, err, "failed to shutdown telemetry")
+
+	assert.Equal(t, 1, paths["/v1/traces"], "trace exports")
+	assert.Equal(t, 1, paths["/v1/metrics"], "metric exports")
+}
+
+func TestSetupDisabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "")
+	assert.False(t, telemetry.Enabled(), "telemetry should be disabled")
+
+	shutdown, err := telemetry.Setup(context.TODO())
+	require.NoError(t, err, "failed to setup telemetry")
+	require.NoError(t, shutdown(context.TODO()), "failed to shutdown telemetry")
+}
+
+func TestPromoteAttributes(t *testing.T) {
+	attrs := telemetry.PromoteAttributes("staging", "myapp", "")
+	require.Len(t, attrs, 2)
+	assert.Equal(t, telemetry.AttributeEnvironment.String("staging"), attrs[0])
+	assert.Equal(t, telemetry.AttributeApp.String("myapp"), attrs[1])
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// maxPayloadSize the maximum size of a webhook payload
+const maxPayloadSize = 25 << 20
+
+// Filter returns true if the webhook is relevant to a subscriber
+type Filter func(hook scm.Webhook) bool
+
+// Listener an HTTP server receiving git provider webhooks, either directly or forwarded by lighthouse, which wakes the
+// subscribers whose filter matches each webhook
+type Listener struct {
+	// Addr the address to listen on such as ':8080'
+	Addr string
+
+	// Secret the HMAC token used to validate webhooks. If empty webhooks are not validated
+	Secret string
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	server      *http.Server
+	listener    net.Listener
+}
+
+type subscriber struct {
+	webhooks scm.WebhookService
+	filter   Filter
+	events   chan struct{}
+}
+
+// Start starts listening for webhooks in the background
+func (l *Listener) Start() error {
+	listener, err := net.Listen("tcp", l.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for webhooks on %s: %w", l.Addr, err)
+	}
+	l.listener = listener
+	l.server = &http.Server{
+		Handler:           l,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := l.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Logger().Warnf("failed to serve webhooks on %s: %s", l.Addr, err.Error())
+		}
+	}()
+	log.Logger().Infof("listening for webhooks on %s", termcolor.ColorInfo(l.Address()))
+	return nil
+}
+
+// Address returns the address the listener is listening on
+func (l *Listener) Address() string {
+	if l.listener == nil {
+		return l.Addr
+	}
+	return l.listener.Addr().String()
+}
+
+// Stop stops listening for webhooks
+func (l *Listener) Stop(ctx context.Context) error {
+	if l.server == nil {
+		return nil
+	}
+	return l.server.Shutdown(ctx)
+}
+
+// Subscribe returns a channel which is signalled when a webhook parsed by the webhook service matches the filter and a
+// function to unsubscribe. Signals are coalesced so that a slow subscriber only sees one pending signal
+func (l *Listener) Subscribe(webhooks scm.WebhookService, filter Filter) (<-chan struct{}, func()) {
+	s := &subscriber{
+		webhooks: webhooks,
+		filter:   filter,
+		events:   make(chan struct{}, 1),
+	}
+	l.mu.Lock()
+	if l.subscribers == nil {
+		l.subscribers = map[*subscriber]struct{}{}
+	}
+	l.subscribers[s] = struct{}{}
+	l.mu.Unlock()
+
+	return s.events, func() {
+		l.mu.Lock()
+		delete(l.subscribers, s)
+		l.mu.Unlock()
+	}
+}
+
+// ServeHTTP parses the webhook for each subscriber and signals those whose filter matches
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "failed to read the webhook", http.StatusBadRequest)
+		return
+	}
+
+	l.mu.Lock()
+	subscribers := make([]*subscriber, 0, len(l.subscribers))
+	for s := range l.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	l.mu.Unlock()
+
+	secretFn := func(scm.Webhook) (string, error) {
+		return l.Secret, nil
+	}
+	// the subscribers may use the clients of different git providers so the webhook is only rejected if none of them
+	// could parse it
+	var parseErr error
+	parsed := false
+	for _, s := range subscribers {
+		req := r.Clone(r.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		hook, err := s.webhooks.Parse(req, secretFn)
+		if scm.IsUnknownWebhook(err) {
+			parsed = true
+			continue
+		}
+		if err != nil {
+			parseErr = err
+			continue
+		}
+		parsed = true
+		if hook == nil || !s.filter(hook) {
+			continue
+		}
+		select {
+		case s.events <- struct{}{}:
+		default:
+		}
+	}
+	if parseErr != nil && !parsed {
+		log.Logger().Debugf("failed to parse webhook: %s", parseErr.Error())
+		http.Error(w, "failed to parse the webhook", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// PullRequestFilter returns a filter matching the webhooks which may change the state of the Pull Request: its
+// pull_request and review events and the status and check events of its repository
+func PullRequestFilter(fullName string, number int) Filter {
+	return func(hook scm.Webhook) bool {
+		if hook.Repository().FullName != fullName {
+			return false
+		}
+		switch h := hook.(type) {
+		case *scm.PullRequestHook:
+			return h.PullRequest.Number == number
+		case *scm.ReviewHook:
+			return h.PullRequest.Number == number
+		case *scm.StatusHook, *scm.CheckRunHook, *scm.CheckSuiteHook:
+			return true
+		default:
+			return false
+		}
+	}
+}
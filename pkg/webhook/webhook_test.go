@@ -0,0 +1,159 @@
+//go:build unit
+// +build unit
+
+package webhook_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/webhook"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "mysecret"
+
+func TestListener(t *testing.T) {
+	l := &webhook.Listener{Addr: "127.0.0.1:0", Secret: secret}
+	err := l.Start()
+	require.NoError(t, err, "failed to start the listener")
+	defer l.Stop(context.TODO()) //nolint:errcheck
+
+	scmClient := github.NewDefault()
+	events, unsubscribe := l.Subscribe(scmClient.Webhooks, webhook.PullRequestFilter("myorg/environment-staging", 5))
+	defer unsubscribe()
+
+	testCases := []struct {
+		name         string
+		event        string
+		payload      string
+		secret       string
+		expectStatus int
+		expectEvent  bool
+	}{
+		{
+			name:         "pull-request",
+			event:        "pull_request",
+			payload:      pullRequestPayload("myorg/environment-staging", 5),
+			secret:       secret,
+			expectStatus: http.StatusOK,
+			expectEvent:  true,
+		},
+		{
+			name:         "other-pull-request",
+			event:        "pull_request",
+			payload:      pullRequestPayload("myorg/environment-staging", 6),
+			secret:       secret,
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "other-repository",
+			event:        "pull_request",
+			payload:      pullRequestPayload("myorg/environment-production", 5),
+			secret:       secret,
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "status",
+			event:        "status",
+			payload:      `{"sha":"abc123","state":"success","context":"verify","repository":{"name":"environment-staging","full_name":"myorg/environment-staging","owner":{"login":"myorg"}}}`,
+			secret:       secret,
+			expectStatus: http.StatusOK,
+			expectEvent:  true,
+		},
+		{
+			name:         "unknown",
+			event:        "gollum",
+			payload:      `{}`,
+			secret:       secret,
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "invalid-signature",
+			event:        "pull_request",
+			payload:      pullRequestPayload("myorg/environment-staging", 5),
+			secret:       "wrong",
+			expectStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := sendWebhook(t, l, tc.event, tc.payload, tc.secret)
+			assert.Equal(t, tc.expectStatus, resp.StatusCode, "status code")
+
+			select {
+			case <-events:
+				assert.True(t, tc.expectEvent, "should not signal the subscriber")
+			case <-time.After(50 * time.Millisecond):
+				assert.False(t, tc.expectEvent, "should signal the subscriber")
+			}
+		})
+	}
+}
+
+func TestListenerMultipleSubscribers(t *testing.T) {
+	l := &webhook.Listener{Addr: "127.0.0.1:0", Secret: secret}
+	err := l.Start()
+	require.NoError(t, err, "failed to start the listener")
+	defer l.Stop(context.TODO()) //nolint:errcheck
+
+	// a subscriber for another git provider cannot parse the webhook
+	_, unsubscribeOther := l.Subscribe(&failingWebhookService{}, webhook.PullRequestFilter("myorg/environment-production", 1))
+	defer unsubscribeOther()
+	events, unsubscribe := l.Subscribe(github.NewDefault().Webhooks, webhook.PullRequestFilter("myorg/environment-staging", 5))
+	defer unsubscribe()
+
+	payload := pullRequestPayload("myorg/environment-staging", 5)
+	resp := sendWebhook(t, l, "pull_request", payload, secret)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "status code when a subscriber parses the webhook")
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		assert.Fail(t, "should signal the subscriber")
+	}
+
+	resp = sendWebhook(t, l, "pull_request", payload, "wrong")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "status code when no subscriber parses the webhook")
+}
+
+// failingWebhookService fails to parse any webhook
+type failingWebhookService struct {
+	scm.WebhookService
+}
+
+func (s *failingWebhookService) Parse(*http.Request, scm.SecretFunc) (scm.Webhook, error) {
+	return nil, fmt.Errorf("unsupported webhook")
+}
+
+func sendWebhook(t *testing.T, l *webhook.Listener, event, payload, key string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, "http://"+l.Address(), bytes.NewBufferString(payload))
+	require.NoError(t, err, "failed to create request")
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(payload))
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", "1234")
+	req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err, "failed to send webhook")
+	resp.Body.Close()
+	return resp
+}
+
+func pullRequestPayload(fullName string, number int) string {
+	owner, name, _ := strings.Cut(fullName, "/")
+	return fmt.Sprintf(`{"action":"synchronize","number":%d,"pull_request":{"number":%d,"state":"open","head":{"ref":"promote","sha":"abc123"},"base":{"ref":"main"}},"repository":{"name":%q,"full_name":%q,"owner":{"login":%q}}}`, number, number, name, fullName, owner)
+}